@@ -1,48 +1,158 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"avito-shop/internal/api"
+	"avito-shop/internal/api/middleware"
 	"avito-shop/internal/config"
-	"avito-shop/internal/repository"
-	"avito-shop/internal/repository/db"
-	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/jobs"
+	"avito-shop/internal/logging"
+	"avito-shop/internal/repository/storage"
 	"avito-shop/internal/service"
+	"avito-shop/internal/tracing"
 )
 
+var errShuttingDown = errors.New("server is shutting down")
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	database, err := db.NewConnection(&cfg.Database)
+	logger, err := newLogger(cfg.Log)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+	// Route the standard log package, still used by some dependencies,
+	// through the same handler.
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg, logger); err != nil {
+		logger.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Server stopped")
+}
+
+// run serves until ctx is cancelled and then shuts down gracefully: /readyz
+// starts failing while the server keeps serving for cfg.Server.ShutdownDelay,
+// the listener is closed, in-flight requests get up to
+// cfg.Server.ShutdownTimeout to finish, and only then are the background
+// jobs stopped and the database pool closed.
+func run(ctx context.Context, cfg *config.Config, logger *slog.Logger) error {
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.OTLPEndpoint)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to set up tracing: %w", err)
 	}
-	defer database.Close()
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
 
-	repos := &repository.Repositories{
-		Users:        postgres.NewUserRepository(database),
-		Merchandise:  postgres.NewMerchandiseRepository(database),
-		Transactions: postgres.NewTransactionRepository(database),
-		Inventory:    postgres.NewUserInventoryRepository(database),
+	store, err := storage.Open(ctx, &cfg.Database, logger)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
 	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			logger.Error("Failed to close storage", "error", err)
+		}
+	}()
 
 	services := service.NewServices(service.ServicesDeps{
-		Repos:       repos,
-		TokenSecret: cfg.JWT.SecretKey,
+		Repos:           store.Repos,
+		TxManager:       store.TxManager,
+		TokenSecret:     cfg.JWT.SecretKey,
+		AccessTokenTTL:  cfg.JWT.AccessTokenTTL,
+		RefreshTokenTTL: cfg.JWT.RefreshTokenTTL,
+		LoginLockout: service.LockoutPolicy{
+			MaxUserAttempts: cfg.Auth.Lockout.MaxUserAttempts,
+			MaxIPAttempts:   cfg.Auth.Lockout.MaxIPAttempts,
+			BaseDelay:       cfg.Auth.Lockout.BaseDelay,
+			MaxDelay:        cfg.Auth.Lockout.MaxDelay,
+			Window:          cfg.Auth.Lockout.Window,
+		},
+		IdempotencyTTL: cfg.Idempotency.TTL,
+		Logger:         logger,
 	})
 
-	router := api.NewRouter(services)
+	// The configured list is authoritative: listed accounts are promoted and
+	// admins removed from it are demoted. Accounts are never created here.
+	missing, err := services.Users.SyncAdmins(ctx, cfg.Admin.Usernames)
+	if err != nil {
+		return fmt.Errorf("failed to sync admin roles: %w", err)
+	}
+	for _, username := range missing {
+		logger.Warn("Configured admin has no account", "username", username)
+	}
+
+	// Background jobs get their own context so that they are stopped only
+	// after the in-flight requests have drained.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobsDone sync.WaitGroup
+	defer func() {
+		stopJobs()
+		jobsDone.Wait()
+	}()
+
+	startJob := func(run func(context.Context)) {
+		jobsDone.Add(1)
+		go func() {
+			defer jobsDone.Done()
+			run(jobsCtx)
+		}()
+	}
+	startJob(jobs.NewTokenCleanupJob(services.Auth, time.Hour, logger).Run)
+	startJob(jobs.NewIdempotencyCleanupJob(services.Idempotency, time.Hour, logger).Run)
+	if cfg.Reconciliation.Enabled {
+		startJob(jobs.NewReconciliationJob(services.Reconciliation, cfg.Reconciliation.Interval, cfg.Reconciliation.AutoFix, logger).Run)
+	}
+
+	var draining atomic.Bool
+	routerConfig := api.RouterConfig{
+		LegacyAutoRegister: cfg.Auth.LegacyAutoRegister,
+		Logger:             logger,
+		Readiness: func(ctx context.Context) error {
+			if draining.Load() {
+				return errShuttingDown
+			}
+			return store.Ready(ctx)
+		},
+	}
+	if cfg.RateLimit.Enabled {
+		routerConfig.RateLimits = api.RateLimits{
+			Auth:  rateLimit(cfg.RateLimit.Auth),
+			Write: rateLimit(cfg.RateLimit.Write),
+			Read:  rateLimit(cfg.RateLimit.Read),
+		}
+	}
+
+	router := api.NewRouter(services, routerConfig)
 	handler := router.Setup()
 
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
-	log.Printf("Server starting on %s", addr)
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      handler,
@@ -50,7 +160,51 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "addr", addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down", "delay", cfg.Server.ShutdownDelay, "timeout", cfg.Server.ShutdownTimeout)
+	draining.Store(true)
+
+	// Load balancers only notice the failing /readyz on their next probe;
+	// until then they keep sending requests, which must not be refused.
+	select {
+	case <-time.After(cfg.Server.ShutdownDelay):
+		logger.Info("Readiness drain window ended; closing the listener")
+	case err := <-serveErr:
+		return err
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Closing the connections cancels the remaining requests, whose
+		// transactions are then rolled back.
+		logger.Error("In-flight requests did not finish in time; closing connections", "error", err)
+		_ = srv.Close()
 	}
+
+	return nil
+}
+
+func newLogger(cfg config.LogConfig) (*slog.Logger, error) {
+	level, err := cfg.SlogLevel()
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, cfg.Format, level)
+}
+
+func rateLimit(rule config.RateLimitRule) middleware.RateLimit {
+	return middleware.RateLimit{Rate: rule.RPS, Burst: rule.Burst}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"avito-shop/internal/config"
+	"avito-shop/internal/migrate"
+	"avito-shop/internal/repository/db"
+	"avito-shop/migrations"
+)
+
+const migrateUsage = `usage: %s migrate [flags] <command>
+
+commands:
+  up              apply all pending migrations
+  down [n]        revert the last n migrations (default 1)
+  status          list migrations and whether they are applied
+  baseline <v>    mark migrations up to version v as applied without running them
+
+flags:
+`
+
+// runMigrate implements the migrate subcommand. It accepts the same
+// configuration flags as the server.
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), migrateUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	dbConfig, err := config.LoadDatabase(fs, args)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if dbConfig.Driver != config.DriverPostgres {
+		log.Fatalf("Migrations apply only to the %s driver", config.DriverPostgres)
+	}
+
+	database, err := db.NewConnection(dbConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	migrator, err := migrate.New(database, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	command, rest := fs.Arg(0), fs.Args()[1:]
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s): %v", len(applied), applied)
+
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps <= 0 {
+				log.Fatalf("Invalid number of steps %q", rest[0])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Printf("Reverted %d migration(s): %v", len(reverted), reverted)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d %-40s %s\n", s.Version, s.Name, state)
+		}
+
+	case "baseline":
+		if len(rest) == 0 {
+			log.Fatalf("baseline requires a version")
+		}
+		version, err := strconv.Atoi(rest[0])
+		if err != nil {
+			log.Fatalf("Invalid version %q", rest[0])
+		}
+		if err := migrator.Baseline(ctx, version); err != nil {
+			log.Fatalf("Baseline failed: %v", err)
+		}
+		log.Printf("Marked migrations up to %d as applied", version)
+
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,60 @@
+// Command reconcile runs the balance reconciliation once and exits with
+// status 1 if any mismatch is left uncorrected.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+
+	"avito-shop/internal/config"
+	"avito-shop/internal/jobs"
+	"avito-shop/internal/repository/storage"
+	"avito-shop/internal/service"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "correct stored balances to match the transaction history")
+
+	// Only the database settings are checked: the command signs no tokens,
+	// so it must not require the server's JWT secret.
+	dbConfig, err := config.LoadDatabase(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// An in-memory store starts empty in every process, so there is nothing
+	// to reconcile in it.
+	if dbConfig.Driver == config.DriverMemory {
+		log.Fatalf("Reconciliation needs a persistent database; the %s driver is not supported", config.DriverMemory)
+	}
+
+	// Migrating is left to the server and the migrate command; a schema that
+	// is out of date fails the queries below instead.
+	dbConfig.AutoMigrate = false
+
+	store, err := storage.Open(context.Background(), dbConfig, slog.Default())
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	reconciliation := service.NewReconciliationService(
+		store.Repos.Transactions,
+		store.Repos.Ledger,
+		store.TxManager,
+	)
+
+	report, err := reconciliation.Reconcile(context.Background(), *fix)
+	if err != nil {
+		log.Fatalf("Failed to reconcile balances: %v", err)
+	}
+	jobs.LogReport(slog.Default(), report)
+
+	if len(report.LedgerMismatches) > 0 || report.Corrected < len(report.HistoryMismatches) {
+		store.Close()
+		os.Exit(1)
+	}
+}
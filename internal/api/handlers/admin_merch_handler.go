@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/service"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type AdminMerchHandler struct {
+	merchandiseService service.MerchandiseService
+	logger             *slog.Logger
+}
+
+func NewAdminMerchHandler(merchandiseService service.MerchandiseService, logger *slog.Logger) *AdminMerchHandler {
+	return &AdminMerchHandler{
+		merchandiseService: merchandiseService,
+		logger:             logger,
+	}
+}
+
+type createMerchRequest struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+type adminMerchListResponse struct {
+	Items []*models.Merchandise `json:"items"`
+}
+
+// ServeHTTP handles GET and POST on the collection and PATCH and DELETE on
+// a single item. The router only sends it those method and path pairs, with
+// the item name in the "name" path value.
+func (h *AdminMerchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	case http.MethodPatch:
+		h.update(w, r, r.PathValue("name"))
+	case http.MethodDelete:
+		h.delete(w, r, r.PathValue("name"))
+	default:
+		respond.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AdminMerchHandler) list(w http.ResponseWriter, r *http.Request) {
+	items, err := h.merchandiseService.ListAll(r.Context())
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	if items == nil {
+		items = []*models.Merchandise{}
+	}
+	respond.JSON(w, adminMerchListResponse{Items: items}, http.StatusOK)
+}
+
+func (h *AdminMerchHandler) create(w http.ResponseWriter, r *http.Request) {
+	var req createMerchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.merchandiseService.Create(r.Context(), req.Name, req.Price)
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, item, http.StatusCreated)
+}
+
+func (h *AdminMerchHandler) update(w http.ResponseWriter, r *http.Request, name string) {
+	var req models.MerchandiseUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := h.merchandiseService.Update(r.Context(), name, req)
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, item, http.StatusOK)
+}
+
+func (h *AdminMerchHandler) delete(w http.ResponseWriter, r *http.Request, name string) {
+	if err := h.merchandiseService.Delete(r.Context(), name); err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
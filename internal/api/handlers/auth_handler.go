@@ -1,54 +1,188 @@
 package handlers
 
 import (
+	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/respond"
 	"avito-shop/internal/service"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 )
 
-type AuthHandler struct {
+type authRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RegisterHandler creates an account and logs it in.
+type RegisterHandler struct {
 	userService service.UserService
+	authService service.AuthService
+	logger      *slog.Logger
 }
 
-func NewAuthHandler(userService service.UserService) *AuthHandler {
-	return &AuthHandler{
+func NewRegisterHandler(userService service.UserService, authService service.AuthService, logger *slog.Logger) *RegisterHandler {
+	return &RegisterHandler{
 		userService: userService,
+		authService: authService,
+		logger:      logger,
 	}
 }
 
-type authRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+func (h *RegisterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req authRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.userService.Register(r.Context(), req.Username, req.Password); err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	tokens, err := h.authService.Login(r.Context(), req.Username, req.Password, middleware.ClientIP(r))
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, tokens, http.StatusCreated)
 }
 
-type authResponse struct {
-	Token string `json:"token"`
+// LoginHandler exchanges the credentials of an existing user for tokens.
+type LoginHandler struct {
+	authService service.AuthService
+	logger      *slog.Logger
 }
 
-func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func NewLoginHandler(authService service.AuthService, logger *slog.Logger) *LoginHandler {
+	return &LoginHandler{
+		authService: authService,
+		logger:      logger,
 	}
+}
 
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var req authRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, "Invalid request body", http.StatusBadRequest)
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	token, err := h.userService.Login(r.Context(), req.Username, req.Password)
+	tokens, err := h.authService.Login(r.Context(), req.Username, req.Password, middleware.ClientIP(r))
 	if err != nil {
-		if err := h.userService.Register(r.Context(), req.Username, req.Password); err != nil {
-			writeError(w, "Failed to authenticate: "+err.Error(), http.StatusUnauthorized)
-			return
-		}
-		token, err = h.userService.Login(r.Context(), req.Username, req.Password)
-		if err != nil {
-			writeError(w, "Failed to login after registration: "+err.Error(), http.StatusInternalServerError)
-			return
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, tokens, http.StatusOK)
+}
+
+// AuthHandler serves the original /api/auth endpoint. With autoRegister set
+// an unknown username is registered on the spot, as clients written before
+// /api/register expect; otherwise it behaves like LoginHandler.
+type AuthHandler struct {
+	userService  service.UserService
+	authService  service.AuthService
+	autoRegister bool
+	logger       *slog.Logger
+}
+
+func NewAuthHandler(userService service.UserService, authService service.AuthService, autoRegister bool, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{
+		userService:  userService,
+		authService:  authService,
+		autoRegister: autoRegister,
+		logger:       logger,
+	}
+}
+
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req authRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.Login(r.Context(), req.Username, req.Password, middleware.ClientIP(r))
+	if err != nil && h.autoRegister && errors.Is(err, service.ErrInvalidCredentials) {
+		// An existing user means the password was wrong, which must not be
+		// reported as a registration failure.
+		err = h.userService.Register(r.Context(), req.Username, req.Password)
+		switch {
+		case errors.Is(err, service.ErrUserExists):
+			err = service.ErrInvalidCredentials
+		case err == nil:
+			tokens, err = h.authService.Login(r.Context(), req.Username, req.Password, middleware.ClientIP(r))
 		}
 	}
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, tokens, http.StatusOK)
+}
+
+type RefreshHandler struct {
+	authService service.AuthService
+	logger      *slog.Logger
+}
+
+func NewRefreshHandler(authService service.AuthService, logger *slog.Logger) *RefreshHandler {
+	return &RefreshHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (h *RefreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, tokens, http.StatusOK)
+}
+
+// LogoutHandler revokes the access token of the request and every refresh
+// token of the same session. It must run after AuthMiddleware.
+type LogoutHandler struct {
+	authService service.AuthService
+	logger      *slog.Logger
+}
+
+func NewLogoutHandler(authService service.AuthService, logger *slog.Logger) *LogoutHandler {
+	return &LogoutHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claims, err := middleware.GetClaims(r.Context())
+	if err != nil {
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.Logout(r.Context(), claims); err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
 
-	writeJSON(w, authResponse{Token: token}, http.StatusOK)
+	writeSuccess(w)
 }
@@ -2,41 +2,39 @@ package handlers
 
 import (
 	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/respond"
 	"avito-shop/internal/service"
+	"log/slog"
 	"net/http"
-	"strings"
 )
 
 type BuyHandler struct {
 	merchandiseService service.MerchandiseService
+	logger             *slog.Logger
 }
 
-func NewBuyHandler(merchandiseService service.MerchandiseService) *BuyHandler {
+func NewBuyHandler(merchandiseService service.MerchandiseService, logger *slog.Logger) *BuyHandler {
 	return &BuyHandler{
 		merchandiseService: merchandiseService,
+		logger:             logger,
 	}
 }
 
 func (h *BuyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, err := middleware.GetUserID(r.Context())
 	if err != nil {
-		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	itemName := strings.TrimPrefix(r.URL.Path, "/api/buy/")
+	itemName := r.PathValue("item")
 	if itemName == "" {
-		writeError(w, "Item name is required", http.StatusBadRequest)
+		respond.Error(w, "Item name is required", http.StatusBadRequest)
 		return
 	}
 
 	if err := h.merchandiseService.BuyItem(r.Context(), userID, itemName); err != nil {
-		writeError(w, "Failed to buy item: "+err.Error(), http.StatusBadRequest)
+		respond.ServiceError(w, r, h.logger, err)
 		return
 	}
 
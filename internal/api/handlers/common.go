@@ -1,30 +1,54 @@
 package handlers
 
 import (
+	"avito-shop/internal/api/respond"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
-type errorResponse struct {
-	Errors string `json:"errors"`
-}
-
 type successResponse struct {
 	Status string `json:"status"`
 }
 
-func writeJSON(w http.ResponseWriter, data interface{}, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
-	}
+func writeSuccess(w http.ResponseWriter) {
+	respond.JSON(w, successResponse{Status: "success"}, http.StatusOK)
 }
 
-func writeError(w http.ResponseWriter, message string, status int) {
-	writeJSON(w, errorResponse{Errors: message}, status)
+// writeJSONWithETag writes data with a strong ETag derived from the encoded
+// body and answers 304 Not Modified when the client already has it.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, data interface{}, status int) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(data); err != nil {
+		respond.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
 }
 
-func writeSuccess(w http.ResponseWriter) {
-	writeJSON(w, successResponse{Status: "success"}, http.StatusOK)
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
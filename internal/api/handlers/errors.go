@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/respond"
+	"net/http"
+)
+
+// WithRoutingErrors serves mux, replacing its plain-text 404 Not Found and
+// 405 Method Not Allowed responses with JSON ones. The Allow header the mux
+// sets for 405 is kept.
+func WithRoutingErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, pattern := mux.Handler(r)
+		if pattern != "" {
+			// Served through the mux so that path values are set.
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &routingErrorRecorder{header: w.Header()}
+		handler.ServeHTTP(rec, r)
+		if rec.status == http.StatusMethodNotAllowed {
+			respond.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		respond.Error(w, "Not found", http.StatusNotFound)
+	})
+}
+
+// routingErrorRecorder captures the status of the mux's error handler and
+// discards its body, while headers go straight to the real response.
+type routingErrorRecorder struct {
+	header http.Header
+	status int
+}
+
+func (r *routingErrorRecorder) Header() http.Header { return r.header }
+
+func (r *routingErrorRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *routingErrorRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return len(b), nil
+}
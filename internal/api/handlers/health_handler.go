@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/respond"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds a readiness check so that a hanging database
+// fails the probe instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler answers liveness probes. It succeeds whenever the process
+// can serve HTTP and does not look at dependencies, so that an unavailable
+// database does not get the service restarted.
+type HealthHandler struct{}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, successResponse{Status: "ok"}, http.StatusOK)
+}
+
+// ReadinessCheck reports why the service cannot take traffic, or nil if it
+// can.
+type ReadinessCheck func(ctx context.Context) error
+
+// ReadinessHandler answers readiness probes with 503 Service Unavailable
+// while check fails. The cause is logged rather than returned because the
+// endpoint is public.
+type ReadinessHandler struct {
+	check  ReadinessCheck
+	logger *slog.Logger
+}
+
+func NewReadinessHandler(check ReadinessCheck, logger *slog.Logger) *ReadinessHandler {
+	return &ReadinessHandler{
+		check:  check,
+		logger: logger,
+	}
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := h.check(ctx); err != nil {
+		h.logger.WarnContext(ctx, "Readiness check failed", "error", err)
+		respond.JSON(w, respond.ErrorBody{Errors: "service is not ready", Code: "not_ready"}, http.StatusServiceUnavailable)
+		return
+	}
+
+	respond.JSON(w, successResponse{Status: "ok"}, http.StatusOK)
+}
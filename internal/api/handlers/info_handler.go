@@ -2,37 +2,36 @@ package handlers
 
 import (
 	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/respond"
 	"avito-shop/internal/service"
+	"log/slog"
 	"net/http"
 )
 
 type InfoHandler struct {
 	infoService service.InfoService
+	logger      *slog.Logger
 }
 
-func NewInfoHandler(infoService service.InfoService) *InfoHandler {
+func NewInfoHandler(infoService service.InfoService, logger *slog.Logger) *InfoHandler {
 	return &InfoHandler{
 		infoService: infoService,
+		logger:      logger,
 	}
 }
 
 func (h *InfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, err := middleware.GetUserID(r.Context())
 	if err != nil {
-		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	info, err := h.infoService.GetUserInfo(r.Context(), userID)
 	if err != nil {
-		writeError(w, "Failed to get user info: "+err.Error(), http.StatusInternalServerError)
+		respond.ServiceError(w, r, h.logger, err)
 		return
 	}
 
-	writeJSON(w, info, http.StatusOK)
+	respond.JSON(w, info, http.StatusOK)
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/service"
+	"log/slog"
+	"net/http"
+)
+
+type MerchHandler struct {
+	merchandiseService service.MerchandiseService
+	logger             *slog.Logger
+}
+
+func NewMerchHandler(merchandiseService service.MerchandiseService, logger *slog.Logger) *MerchHandler {
+	return &MerchHandler{
+		merchandiseService: merchandiseService,
+		logger:             logger,
+	}
+}
+
+type merchItem struct {
+	Name      string `json:"name"`
+	Price     int    `json:"price"`
+	Available bool   `json:"available"`
+}
+
+type merchCatalogResponse struct {
+	Items []merchItem `json:"items"`
+}
+
+func newMerchItem(item *models.Merchandise) merchItem {
+	return merchItem{
+		Name:      item.Name,
+		Price:     item.Price,
+		Available: item.Active,
+	}
+}
+
+// ServeHTTP serves a single item when the route has a "name" path value and
+// the whole catalogue otherwise.
+func (h *MerchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name == "" {
+		h.serveCatalog(w, r)
+		return
+	}
+
+	item, err := h.merchandiseService.GetByName(r.Context(), name)
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	writeJSONWithETag(w, r, newMerchItem(item), http.StatusOK)
+}
+
+func (h *MerchHandler) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	items, err := h.merchandiseService.GetAll(r.Context())
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	resp := merchCatalogResponse{Items: make([]merchItem, 0, len(items))}
+	for _, item := range items {
+		resp.Items = append(resp.Items, newMerchItem(item))
+	}
+
+	writeJSONWithETag(w, r, resp, http.StatusOK)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type TransactionsHandler struct {
+	transactionService service.TransactionService
+	logger             *slog.Logger
+}
+
+func NewTransactionsHandler(transactionService service.TransactionService, logger *slog.Logger) *TransactionsHandler {
+	return &TransactionsHandler{
+		transactionService: transactionService,
+		logger:             logger,
+	}
+}
+
+func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r.Context())
+	if err != nil {
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.TransactionFilter{
+		UserID:       userID,
+		Direction:    query.Get("direction"),
+		Type:         query.Get("type"),
+		Counterparty: query.Get("counterparty"),
+	}
+
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		respond.Error(w, "Invalid from: expected RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		respond.Error(w, "Invalid to: expected RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 {
+			respond.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.transactionService.ListTransactions(r.Context(), filter, query.Get("cursor"))
+	if err != nil {
+		respond.ServiceError(w, r, h.logger, err)
+		return
+	}
+
+	respond.JSON(w, page, http.StatusOK)
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
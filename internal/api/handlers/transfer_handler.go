@@ -2,18 +2,22 @@ package handlers
 
 import (
 	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/respond"
 	"avito-shop/internal/service"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 )
 
 type TransferHandler struct {
 	userService service.UserService
+	logger      *slog.Logger
 }
 
-func NewTransferHandler(userService service.UserService) *TransferHandler {
+func NewTransferHandler(userService service.UserService, logger *slog.Logger) *TransferHandler {
 	return &TransferHandler{
 		userService: userService,
+		logger:      logger,
 	}
 }
 
@@ -23,25 +27,20 @@ type transferRequest struct {
 }
 
 func (h *TransferHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	userID, err := middleware.GetUserID(r.Context())
 	if err != nil {
-		writeError(w, "Unauthorized", http.StatusUnauthorized)
+		respond.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	var req transferRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, "Invalid request body", http.StatusBadRequest)
+		respond.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	if err := h.userService.TransferCoins(r.Context(), userID, req.ToUser, req.Amount); err != nil {
-		writeError(w, "Failed to transfer coins: "+err.Error(), http.StatusBadRequest)
+		respond.ServiceError(w, r, h.logger, err)
 		return
 	}
 
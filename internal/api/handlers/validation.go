@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"avito-shop/internal/api/openapi"
+	"avito-shop/internal/api/respond"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxRequestBodyBytes bounds every request body. The largest documented
+// body, a merchandise item, is well under a kilobyte.
+const maxRequestBodyBytes = 64 << 10
+
+// ValidateRequest rejects requests whose body does not match the JSON schema
+// of op: malformed JSON, unknown fields, missing required fields and values
+// of the wrong type are answered with 400 Bad Request listing every
+// violation in details, and bodies over maxRequestBodyBytes with 413. Bodies
+// of operations without a documented schema are only limited in size, as
+// are all bodies when op is nil.
+func ValidateRequest(op *openapi.Operation) func(http.Handler) http.Handler {
+	schema := op.BodySchema()
+	required := schema != nil && op.RequestBody.Required
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+			if schema == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					respond.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				respond.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+
+			if details := validateBody(schema, required, body); len(details) > 0 {
+				respond.JSON(w, respond.ErrorBody{
+					Errors:  "Request body does not match the API schema",
+					Code:    "bad_request",
+					Details: details,
+				}, http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func validateBody(schema *openapi.Schema, required bool, body []byte) []openapi.FieldError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required {
+			return []openapi.FieldError{{Message: "request body is required"}}
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []openapi.FieldError{{Message: "must be valid JSON"}}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return []openapi.FieldError{{Message: "must contain a single JSON value"}}
+	}
+
+	return schema.Validate(value)
+}
@@ -1,50 +1,65 @@
 package api
 
 import (
+	"avito-shop/internal/api/openapi"
 	"avito-shop/internal/domain/models"
 	"avito-shop/internal/repository"
-	"avito-shop/internal/repository/postgres"
 	"avito-shop/internal/service"
-	"avito-shop/internal/test"
+	"avito-shop/internal/test/backend"
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type testServer struct {
-	db       *sql.DB
-	handler  http.Handler
-	cleanup  func()
-	services *service.Services
+	repos     *repository.Repositories
+	txManager repository.TxManager
+	handler   http.Handler
+	services  *service.Services
 }
 
 func setupTestServer(t *testing.T) *testServer {
-	db, cleanup := test.SetupTestDB(t)
-
-	repos := &repository.Repositories{
-		Users:        postgres.NewUserRepository(db),
-		Merchandise:  postgres.NewMerchandiseRepository(db),
-		Transactions: postgres.NewTransactionRepository(db),
-		Inventory:    postgres.NewUserInventoryRepository(db),
-	}
+	repos, txManager := backend.New(t)
 
 	services := service.NewServices(service.ServicesDeps{
 		Repos:       repos,
+		TxManager:   txManager,
 		TokenSecret: "test-secret",
 	})
 
-	router := NewRouter(services)
+	router := NewRouter(services, RouterConfig{LegacyAutoRegister: true})
 	handler := router.Setup()
 
 	return &testServer{
-		db:       db,
-		handler:  handler,
-		cleanup:  cleanup,
-		services: services,
+		repos:     repos,
+		txManager: txManager,
+		handler:   handler,
+		services:  services,
+	}
+}
+
+// addMerch puts an item on sale.
+func (ts *testServer) addMerch(t *testing.T, name string, price int) {
+	t.Helper()
+	item := &models.Merchandise{Name: name, Price: price, Active: true}
+	if err := ts.repos.Merchandise.Create(context.Background(), item); err != nil {
+		t.Fatalf("Failed to create merchandise %s: %v", name, err)
+	}
+}
+
+// makeAdmin grants the admin role to an existing account, the way the
+// server does at start-up for the configured administrators.
+func (ts *testServer) makeAdmin(t *testing.T, username string) {
+	t.Helper()
+	missing, err := ts.services.Users.SyncAdmins(context.Background(), []string{username})
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("Failed to make %s an admin: missing %v, error %v", username, missing, err)
 	}
 }
 
@@ -56,15 +71,8 @@ func (ts *testServer) executeRequest(req *http.Request) *httptest.ResponseRecord
 
 func TestFullUserFlow(t *testing.T) {
 	ts := setupTestServer(t)
-	defer ts.cleanup()
 
-	_, err := ts.db.Exec(`
-		INSERT INTO merchandise (name, price) 
-		VALUES ('test-item', 100)
-	`)
-	if err != nil {
-		t.Fatalf("Failed to insert test merchandise: %v", err)
-	}
+	ts.addMerch(t, "test-item", 100)
 
 	recipientBody := map[string]string{
 		"username": "recipient",
@@ -92,7 +100,7 @@ func TestFullUserFlow(t *testing.T) {
 	var loginResp struct {
 		Token string `json:"token"`
 	}
-	err = json.NewDecoder(resp.Body).Decode(&loginResp)
+	err := json.NewDecoder(resp.Body).Decode(&loginResp)
 	if err != nil {
 		t.Fatalf("Failed to decode login response: %v", err)
 	}
@@ -151,7 +159,6 @@ func TestFullUserFlow(t *testing.T) {
 
 func TestAuthFlow(t *testing.T) {
 	ts := setupTestServer(t)
-	defer ts.cleanup()
 
 	tests := []struct {
 		name         string
@@ -166,10 +173,16 @@ func TestAuthFlow(t *testing.T) {
 			expectedCode: http.StatusOK,
 		},
 		{
-			name:         "Duplicate registration",
+			name:         "Existing user logs in",
 			username:     "newuser",
 			password:     "password123",
-			expectedCode: http.StatusBadRequest,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Wrong password",
+			username:     "newuser",
+			password:     "wrongpass",
+			expectedCode: http.StatusUnauthorized,
 		},
 		{
 			name:         "Empty username",
@@ -196,9 +209,204 @@ func TestAuthFlow(t *testing.T) {
 	}
 }
 
+func TestRegisterAndLogin(t *testing.T) {
+	ts := setupTestServer(t)
+
+	tests := []struct {
+		name         string
+		path         string
+		username     string
+		password     string
+		expectedCode int
+	}{
+		{
+			name:         "Login before registration",
+			path:         "/api/login",
+			username:     "newuser",
+			password:     "password123",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Registration",
+			path:         "/api/register",
+			username:     "newuser",
+			password:     "password123",
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Duplicate registration",
+			path:         "/api/register",
+			username:     "newuser",
+			password:     "other",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "Registration without password",
+			path:         "/api/register",
+			username:     "other",
+			password:     "",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Login",
+			path:         "/api/login",
+			username:     "newuser",
+			password:     "password123",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Login with wrong password",
+			path:         "/api/login",
+			username:     "newuser",
+			password:     "wrongpass",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"username": tt.username, "password": tt.password})
+			resp := ts.executeRequest(httptest.NewRequest("POST", tt.path, bytes.NewBuffer(body)))
+			if resp.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.Code)
+			}
+		})
+	}
+}
+
+func TestAuthWithoutAutoRegister(t *testing.T) {
+	ts := setupTestServer(t)
+
+	handler := NewRouter(ts.services, RouterConfig{}).Setup()
+	body, _ := json.Marshal(map[string]string{"username": "newuser", "password": "password123"})
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for unknown user, got %d", http.StatusUnauthorized, resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("POST", "/api/register", bytes.NewBuffer(body)))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d on registration, got %d", http.StatusCreated, resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for registered user, got %d", http.StatusOK, resp.Code)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ts := setupTestServer(t)
+
+	services := service.NewServices(service.ServicesDeps{
+		Repos:       ts.repos,
+		TxManager:   ts.txManager,
+		TokenSecret: "test-secret",
+		LoginLockout: service.LockoutPolicy{
+			MaxUserAttempts: 1,
+			BaseDelay:       time.Minute,
+			MaxDelay:        time.Minute,
+			Window:          time.Hour,
+		},
+	})
+	handler := NewRouter(services, RouterConfig{LegacyAutoRegister: true}).Setup()
+
+	auth := func(password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"username": "testuser", "password": password})
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+		return resp
+	}
+
+	if resp := auth("testpass"); resp.Code != http.StatusOK {
+		t.Fatalf("Failed to register: status code %d", resp.Code)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := auth("wrongpass"); resp.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d for wrong password, got %d", http.StatusUnauthorized, resp.Code)
+		}
+	}
+
+	resp := auth("testpass")
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status code %d while locked out, got %d", http.StatusTooManyRequests, resp.Code)
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header while locked out")
+	}
+	var errResp struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Code != "login_locked" {
+		t.Errorf("Expected error code %q, got %q (%v)", "login_locked", errResp.Code, err)
+	}
+}
+
+func TestTokenRefreshAndLogout(t *testing.T) {
+	ts := setupTestServer(t)
+
+	body, _ := json.Marshal(map[string]string{"username": "testuser", "password": "testpass"})
+	resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Failed to authenticate: status code %d", resp.Code)
+	}
+
+	var login models.TokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+	if login.RefreshToken == "" || login.ExpiresIn <= 0 {
+		t.Fatalf("Expected refresh token and expiry in login response, got %+v", login)
+	}
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"refreshToken": token})
+		return ts.executeRequest(httptest.NewRequest("POST", "/api/auth/refresh", bytes.NewBuffer(body)))
+	}
+
+	resp = refresh(login.RefreshToken)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d on refresh, got %d", http.StatusOK, resp.Code)
+	}
+	var refreshed models.TokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&refreshed); err != nil {
+		t.Fatalf("Failed to decode refresh response: %v", err)
+	}
+
+	if resp := refresh("invalid-token"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for unknown refresh token, got %d", http.StatusUnauthorized, resp.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	if resp := ts.executeRequest(req); resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d on logout, got %d", http.StatusOK, resp.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/info", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshed.AccessToken)
+	resp = ts.executeRequest(req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d after logout, got %d", http.StatusUnauthorized, resp.Code)
+	}
+	var errResp struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Code != "token_revoked" {
+		t.Errorf("Expected error code %q after logout, got %q (%v)", "token_revoked", errResp.Code, err)
+	}
+
+	if resp := refresh(refreshed.RefreshToken); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d for refresh after logout, got %d", http.StatusUnauthorized, resp.Code)
+	}
+}
+
 func TestInfoFlow(t *testing.T) {
 	ts := setupTestServer(t)
-	defer ts.cleanup()
 
 	registerBody := map[string]string{
 		"username": "testuser",
@@ -257,20 +465,15 @@ func TestInfoFlow(t *testing.T) {
 
 func TestErrorScenarios(t *testing.T) {
 	ts := setupTestServer(t)
-	defer ts.cleanup()
-
-	user := map[string]string{
-		"username": "testuser",
-		"password": "testpass",
-	}
-	body, _ := json.Marshal(user)
-	req := httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body))
-	resp := ts.executeRequest(req)
 
 	var loginResp struct {
 		Token string `json:"token"`
 	}
-	json.NewDecoder(resp.Body).Decode(&loginResp)
+	for _, username := range []string{"recipient", "testuser"} {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "testpass"})
+		resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+		json.NewDecoder(resp.Body).Decode(&loginResp)
+	}
 
 	tests := []struct {
 		name         string
@@ -279,6 +482,7 @@ func TestErrorScenarios(t *testing.T) {
 		body         interface{}
 		token        string
 		expectedCode int
+		expectedErr  string
 	}{
 		{
 			name:         "Buy non-existent item",
@@ -286,6 +490,7 @@ func TestErrorScenarios(t *testing.T) {
 			path:         "/api/buy/non-existent",
 			token:        loginResp.Token,
 			expectedCode: http.StatusNotFound,
+			expectedErr:  "item_not_found",
 		},
 		{
 			name:         "Transfer negative amount",
@@ -294,6 +499,7 @@ func TestErrorScenarios(t *testing.T) {
 			body:         map[string]interface{}{"toUser": "other", "amount": -100},
 			token:        loginResp.Token,
 			expectedCode: http.StatusBadRequest,
+			expectedErr:  "invalid_amount",
 		},
 		{
 			name:         "Transfer to non-existent user",
@@ -302,6 +508,25 @@ func TestErrorScenarios(t *testing.T) {
 			body:         map[string]interface{}{"toUser": "nonexistent", "amount": 100},
 			token:        loginResp.Token,
 			expectedCode: http.StatusNotFound,
+			expectedErr:  "recipient_not_found",
+		},
+		{
+			name:         "Transfer more than balance",
+			method:       "POST",
+			path:         "/api/sendCoin",
+			body:         map[string]interface{}{"toUser": "recipient", "amount": 5000},
+			token:        loginResp.Token,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedErr:  "insufficient_funds",
+		},
+		{
+			name:         "Malformed body",
+			method:       "POST",
+			path:         "/api/sendCoin",
+			body:         "not an object",
+			token:        loginResp.Token,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "bad_request",
 		},
 	}
 
@@ -325,6 +550,443 @@ func TestErrorScenarios(t *testing.T) {
 			if resp.Code != tt.expectedCode {
 				t.Errorf("Expected status code %d, got %d", tt.expectedCode, resp.Code)
 			}
+
+			var errResp struct {
+				Errors string `json:"errors"`
+				Code   string `json:"code"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if errResp.Code != tt.expectedErr {
+				t.Errorf("Expected error code %q, got %q (%s)", tt.expectedErr, errResp.Code, errResp.Errors)
+			}
 		})
 	}
 }
+
+func TestIdempotentTransfer(t *testing.T) {
+	ts := setupTestServer(t)
+
+	var token string
+	for _, username := range []string{"recipient", "testuser"} {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "testpass"})
+		resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Failed to authenticate %s: status code %d", username, resp.Code)
+		}
+
+		var loginResp struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Failed to decode login response: %v", err)
+		}
+		token = loginResp.Token
+	}
+
+	sendCoin := func(key string, amount int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"toUser": "recipient", "amount": amount})
+		req := httptest.NewRequest("POST", "/api/sendCoin", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Idempotency-Key", key)
+		return ts.executeRequest(req)
+	}
+
+	first := sendCoin("transfer-1", 100)
+	if first.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, first.Code)
+	}
+
+	retry := sendCoin("transfer-1", 100)
+	if retry.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d on retry, got %d", http.StatusOK, retry.Code)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected retried response to be marked as replayed")
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Replayed body = %q, want %q", retry.Body.String(), first.Body.String())
+	}
+
+	mismatch := sendCoin("transfer-1", 200)
+	if mismatch.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d for reused key, got %d", http.StatusUnprocessableEntity, mismatch.Code)
+	}
+	var mismatchResp struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(mismatch.Body).Decode(&mismatchResp); err != nil || mismatchResp.Code != "idempotency_key_mismatch" {
+		t.Errorf("Reused key error code = %q, %v, want idempotency_key_mismatch", mismatchResp.Code, err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/info", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := ts.executeRequest(req)
+
+	var infoResp models.InfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&infoResp); err != nil {
+		t.Fatalf("Failed to decode info response: %v", err)
+	}
+	if infoResp.Coins != 900 {
+		t.Errorf("Expected %d coins, got %d", 900, infoResp.Coins)
+	}
+}
+
+func TestTransactionHistory(t *testing.T) {
+	ts := setupTestServer(t)
+
+	ts.addMerch(t, "test-item", 100)
+
+	tokens := map[string]string{}
+	for _, username := range []string{"recipient", "testuser"} {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "testpass"})
+		resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Failed to authenticate %s: status code %d", username, resp.Code)
+		}
+
+		var loginResp struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Failed to decode login response: %v", err)
+		}
+		tokens[username] = loginResp.Token
+	}
+
+	for _, amount := range []int{10, 20, 30} {
+		body, _ := json.Marshal(map[string]interface{}{"toUser": "recipient", "amount": amount})
+		req := httptest.NewRequest("POST", "/api/sendCoin", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+tokens["testuser"])
+		if resp := ts.executeRequest(req); resp.Code != http.StatusOK {
+			t.Fatalf("Failed to transfer coins: status code %d", resp.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/buy/test-item", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["testuser"])
+	if resp := ts.executeRequest(req); resp.Code != http.StatusOK {
+		t.Fatalf("Failed to buy item: status code %d", resp.Code)
+	}
+
+	list := func(username, query string) models.TransactionPage {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/transactions"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+tokens[username])
+		resp := ts.executeRequest(req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("GET /api/transactions%s: status code %d", query, resp.Code)
+		}
+
+		var page models.TransactionPage
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatalf("Failed to decode transactions: %v", err)
+		}
+		return page
+	}
+
+	first := list("testuser", "?limit=3")
+	if len(first.Transactions) != 3 || first.NextCursor == "" {
+		t.Fatalf("First page = %d items, cursor %q; want 3 items and a cursor", len(first.Transactions), first.NextCursor)
+	}
+	if first.Transactions[0].Type != models.TransactionTypePurchase || first.Transactions[0].Counterparty != models.ShopAccountName {
+		t.Errorf("Newest transaction = %+v, want purchase from SHOP", first.Transactions[0])
+	}
+
+	second := list("testuser", "?limit=3&cursor="+first.NextCursor)
+	if len(second.Transactions) != 1 || second.NextCursor != "" {
+		t.Fatalf("Second page = %d items, cursor %q; want 1 item and no cursor", len(second.Transactions), second.NextCursor)
+	}
+	if second.Transactions[0].Amount != 10 {
+		t.Errorf("Oldest transaction amount = %d, want %d", second.Transactions[0].Amount, 10)
+	}
+
+	transfers := list("testuser", "?type=TRANSFER&counterparty=recipient")
+	if len(transfers.Transactions) != 3 {
+		t.Errorf("Transfers to recipient = %d, want %d", len(transfers.Transactions), 3)
+	}
+
+	received := list("recipient", "?direction=received")
+	if len(received.Transactions) != 3 {
+		t.Errorf("Received transactions = %d, want %d", len(received.Transactions), 3)
+	}
+	for _, item := range received.Transactions {
+		if item.Direction != models.DirectionReceived || item.Counterparty != "testuser" {
+			t.Errorf("Received transaction = %+v, want direction received from testuser", item)
+		}
+	}
+
+	req = httptest.NewRequest("GET", "/api/transactions?direction=sideways", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["testuser"])
+	if resp := ts.executeRequest(req); resp.Code != http.StatusBadRequest {
+		t.Errorf("Invalid direction: status code %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+}
+
+func TestMerchCatalog(t *testing.T) {
+	ts := setupTestServer(t)
+
+	ts.addMerch(t, "t-shirt", 80)
+	ts.addMerch(t, "cup", 20)
+
+	body, _ := json.Marshal(map[string]string{"username": "testuser", "password": "testpass"})
+	resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+	var loginResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+loginResp.Token)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		return ts.executeRequest(req)
+	}
+
+	resp = get("/api/merch", "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.Code)
+	}
+
+	var catalog struct {
+		Items []struct {
+			Name  string `json:"name"`
+			Price int    `json:"price"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatalf("Failed to decode catalog: %v", err)
+	}
+	if len(catalog.Items) != 2 || catalog.Items[0].Name != "cup" || catalog.Items[0].Price != 20 {
+		t.Errorf("Catalog = %+v, want cup and t-shirt sorted by name", catalog.Items)
+	}
+
+	etag := resp.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+	if resp := get("/api/merch", etag); resp.Code != http.StatusNotModified {
+		t.Errorf("Expected status code %d for matching ETag, got %d", http.StatusNotModified, resp.Code)
+	}
+
+	cup, err := ts.repos.Merchandise.GetByName(context.Background(), "cup")
+	if err != nil || cup == nil {
+		t.Fatalf("Failed to get cup: %v", err)
+	}
+	cup.Price = 25
+	if err := ts.repos.Merchandise.Update(context.Background(), cup); err != nil {
+		t.Fatalf("Failed to update price: %v", err)
+	}
+	if resp := get("/api/merch", etag); resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d after price change, got %d", http.StatusOK, resp.Code)
+	}
+
+	if resp := get("/api/merch/cup", ""); resp.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for existing item, got %d", http.StatusOK, resp.Code)
+	}
+	if resp := get("/api/merch/hoody", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for missing item, got %d", http.StatusNotFound, resp.Code)
+	}
+}
+
+func TestAdminMerchandise(t *testing.T) {
+	ts := setupTestServer(t)
+
+	if err := ts.services.Users.Register(context.Background(), "admin", "testpass"); err != nil {
+		t.Fatalf("Failed to register admin: %v", err)
+	}
+	ts.makeAdmin(t, "admin")
+
+	tokens := map[string]string{}
+	for _, username := range []string{"admin", "testuser"} {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": "testpass"})
+		resp := ts.executeRequest(httptest.NewRequest("POST", "/api/auth", bytes.NewBuffer(body)))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Failed to authenticate %s: status code %d", username, resp.Code)
+		}
+
+		var loginResp struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&loginResp); err != nil {
+			t.Fatalf("Failed to decode login response: %v", err)
+		}
+		tokens[username] = loginResp.Token
+	}
+
+	do := func(username, method, path string, body interface{}) *httptest.ResponseRecorder {
+		var reqBody io.Reader
+		if body != nil {
+			jsonBody, _ := json.Marshal(body)
+			reqBody = bytes.NewBuffer(jsonBody)
+		}
+		req := httptest.NewRequest(method, path, reqBody)
+		req.Header.Set("Authorization", "Bearer "+tokens[username])
+		return ts.executeRequest(req)
+	}
+
+	tests := []struct {
+		name         string
+		username     string
+		method       string
+		path         string
+		body         interface{}
+		expectedCode int
+	}{
+		{"Non-admin cannot create", "testuser", "POST", "/api/admin/merch", map[string]interface{}{"name": "cup", "price": 20}, http.StatusForbidden},
+		{"Create item", "admin", "POST", "/api/admin/merch", map[string]interface{}{"name": "cup", "price": 20}, http.StatusCreated},
+		{"Create duplicate", "admin", "POST", "/api/admin/merch", map[string]interface{}{"name": "cup", "price": 30}, http.StatusConflict},
+		{"Create with invalid price", "admin", "POST", "/api/admin/merch", map[string]interface{}{"name": "pen", "price": 0}, http.StatusBadRequest},
+		{"Create spare item", "admin", "POST", "/api/admin/merch", map[string]interface{}{"name": "pen", "price": 10}, http.StatusCreated},
+		{"Update price", "admin", "PATCH", "/api/admin/merch/cup", map[string]interface{}{"price": 25}, http.StatusOK},
+		{"Buy item", "testuser", "GET", "/api/buy/cup", nil, http.StatusOK},
+		{"Rename item", "admin", "PATCH", "/api/admin/merch/cup", map[string]interface{}{"name": "mug"}, http.StatusOK},
+		{"Delete purchased item", "admin", "DELETE", "/api/admin/merch/mug", nil, http.StatusConflict},
+		{"Deactivate item", "admin", "PATCH", "/api/admin/merch/mug", map[string]interface{}{"active": false}, http.StatusOK},
+		{"Buy deactivated item", "testuser", "GET", "/api/buy/mug", nil, http.StatusConflict},
+		{"Delete unpurchased item", "admin", "DELETE", "/api/admin/merch/pen", nil, http.StatusNoContent},
+		{"Update missing item", "admin", "PATCH", "/api/admin/merch/pen", map[string]interface{}{"price": 5}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(tt.username, tt.method, tt.path, tt.body)
+			if resp.Code != tt.expectedCode {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedCode, resp.Code, resp.Body.String())
+			}
+			if resp.Code == http.StatusForbidden {
+				var errResp struct {
+					Code string `json:"code"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Code != "forbidden" {
+					t.Errorf("Expected error code %q, got %q (%v)", "forbidden", errResp.Code, err)
+				}
+			}
+		})
+	}
+
+	resp := do("testuser", "GET", "/api/merch", nil)
+	var catalog struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatalf("Failed to decode catalog: %v", err)
+	}
+	if len(catalog.Items) != 0 {
+		t.Errorf("Catalog = %+v, want deactivated item hidden", catalog.Items)
+	}
+
+	resp = do("testuser", "GET", "/api/info", nil)
+	var info models.InfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode info response: %v", err)
+	}
+	if len(info.Inventory) != 1 || info.Inventory[0].Type != "mug" {
+		t.Errorf("Inventory = %+v, want the retired item kept", info.Inventory)
+	}
+}
+
+// checkResponse fails t if resp has a status the OpenAPI document does not
+// list for method requests to pattern, or a body that does not match the
+// documented schema.
+func checkResponse(t *testing.T, method, pattern string, resp *httptest.ResponseRecorder) {
+	t.Helper()
+
+	op := openapi.Spec().Operation(method, pattern)
+	if op == nil {
+		t.Fatalf("%s %s is not documented", method, pattern)
+	}
+	if _, ok := op.Responses[strconv.Itoa(resp.Code)]; !ok {
+		t.Errorf("%s %s returned undocumented status %d", method, pattern, resp.Code)
+		return
+	}
+
+	schema := op.ResponseSchema(resp.Code)
+	if schema == nil {
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(resp.Body.Bytes()))
+	dec.UseNumber()
+	var body interface{}
+	if err := dec.Decode(&body); err != nil {
+		t.Errorf("%s %s returned invalid JSON: %v", method, pattern, err)
+		return
+	}
+	if errs := schema.Validate(body); len(errs) > 0 {
+		t.Errorf("%s %s %d response does not match the schema: %v", method, pattern, resp.Code, errs)
+	}
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	ts := setupTestServer(t)
+
+	do := func(method, path, pattern, token string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var reqBody io.Reader
+		if body != nil {
+			jsonBody, _ := json.Marshal(body)
+			reqBody = bytes.NewBuffer(jsonBody)
+		}
+		req := httptest.NewRequest(method, path, reqBody)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := ts.executeRequest(req)
+		checkResponse(t, method, pattern, resp)
+		return resp
+	}
+
+	credentials := map[string]string{"username": "admin", "password": "testpass"}
+	do("POST", "/api/v2/auth/register", "/api/v2/auth/register", "", credentials)
+	do("POST", "/api/v2/auth/register", "/api/v2/auth/register", "", credentials)
+	do("POST", "/api/v2/auth/register", "/api/v2/auth/register", "", map[string]string{"username": "recipient", "password": "testpass"})
+
+	var tokens struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	ts.makeAdmin(t, "admin")
+	resp := do("POST", "/api/v2/auth/login", "/api/v2/auth/login", "", credentials)
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+	do("POST", "/api/v2/auth/login", "/api/v2/auth/login", "", map[string]string{"username": "admin", "password": "wrong"})
+	do("POST", "/api/auth", "/api/auth", "", credentials)
+
+	do("POST", "/api/v2/admin/merch", "/api/v2/admin/merch", tokens.Token, map[string]interface{}{"name": "cup", "price": 20})
+	do("POST", "/api/v2/admin/merch", "/api/v2/admin/merch", tokens.Token, map[string]interface{}{"name": "cup", "price": 20})
+	do("GET", "/api/v2/admin/merch", "/api/v2/admin/merch", tokens.Token, nil)
+	do("PATCH", "/api/v2/admin/merch/cup", "/api/v2/admin/merch/{name}", tokens.Token, map[string]interface{}{"price": 25})
+	do("GET", "/api/v2/merch", "/api/v2/merch", tokens.Token, nil)
+	do("GET", "/api/v2/merch/cup", "/api/v2/merch/{name}", tokens.Token, nil)
+	do("GET", "/api/v2/merch/pen", "/api/v2/merch/{name}", tokens.Token, nil)
+
+	do("POST", "/api/v2/buy/cup", "/api/v2/buy/{item}", tokens.Token, nil)
+	do("POST", "/api/v2/buy/pen", "/api/v2/buy/{item}", tokens.Token, nil)
+	do("POST", "/api/v2/sendCoin", "/api/v2/sendCoin", tokens.Token, map[string]interface{}{"toUser": "recipient", "amount": 100})
+	do("POST", "/api/v2/sendCoin", "/api/v2/sendCoin", tokens.Token, map[string]interface{}{"toUser": "recipient", "amount": 5000})
+	do("POST", "/api/v2/sendCoin", "/api/v2/sendCoin", tokens.Token, map[string]interface{}{"toUser": "recipient", "amount": "100"})
+	do("GET", "/api/v2/info", "/api/v2/info", tokens.Token, nil)
+	do("GET", "/api/v2/transactions?limit=1", "/api/v2/transactions", tokens.Token, nil)
+
+	do("POST", "/api/v2/auth/refresh", "/api/v2/auth/refresh", "", map[string]string{"refreshToken": tokens.RefreshToken})
+	do("POST", "/api/v2/auth/logout", "/api/v2/auth/logout", tokens.Token, nil)
+
+	do("GET", "/api/v2/info", "/api/v2/info", "", nil)
+	do("GET", "/api/v2/info", "/api/v2/info", tokens.Token, nil)
+	var recipient struct {
+		Token string `json:"token"`
+	}
+	resp = do("POST", "/api/v2/auth/login", "/api/v2/auth/login", "", map[string]string{"username": "recipient", "password": "testpass"})
+	if err := json.NewDecoder(resp.Body).Decode(&recipient); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+	do("GET", "/api/v2/admin/merch", "/api/v2/admin/merch", recipient.Token, nil)
+}
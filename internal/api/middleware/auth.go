@@ -1,57 +1,88 @@
 package middleware
 
 import (
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/service"
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "user_id"
+const (
+	UserIDKey   contextKey = "user_id"
+	UserRoleKey contextKey = "role"
+	ClaimsKey   contextKey = "claims"
+)
+
+var (
+	errAuthHeaderMissing   = &service.Error{Kind: service.KindUnauthorized, Code: "invalid_token", Message: "authorization header is required"}
+	errAuthHeaderMalformed = &service.Error{Kind: service.KindUnauthorized, Code: "invalid_token", Message: "invalid authorization header format"}
+)
+
+// AuthMiddleware admits requests carrying a valid, unrevoked access token in
+// the Authorization header. Rejections are JSON errors with the code
+// invalid_token or token_revoked.
+func AuthMiddleware(auth service.AuthService, logger *slog.Logger) func(http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
 
-func AuthMiddleware(secretKey string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
-				return
-			}
-
-			bearerToken := strings.Split(authHeader, " ")
-			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-				return
+			ctx, span := tracer.Start(r.Context(), "AuthMiddleware")
+			claims, err := authenticate(r.WithContext(ctx), auth)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			} else {
+				span.SetAttributes(attribute.Int64("user.id", claims.UserID))
 			}
-
-			tokenStr := bearerToken[1]
-			token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(secretKey), nil
-			})
+			span.End()
 
 			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				respond.ServiceError(w, r, logger, err)
 				return
 			}
 
-			if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-				userID := int64(claims["user_id"].(float64))
-				ctx := context.WithValue(r.Context(), UserIDKey, userID)
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			if info := getRequestInfo(r.Context()); info != nil {
+				info.userID = claims.UserID
 			}
+
+			ctx = context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
+			ctx = context.WithValue(ctx, ClaimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// authenticate verifies the bearer token of r. Token problems are reported
+// as *service.Error; any other error is internal.
+func authenticate(r *http.Request, auth service.AuthService) (*models.AccessClaims, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errAuthHeaderMissing
+	}
+
+	bearerToken := strings.Split(authHeader, " ")
+	if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+		return nil, errAuthHeaderMalformed
+	}
+
+	claims, err := auth.ParseAccessToken(r.Context(), bearerToken[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+
+	return claims, nil
+}
+
 func GetUserID(ctx context.Context) (int64, error) {
 	userID, ok := ctx.Value(UserIDKey).(int64)
 	if !ok {
@@ -59,3 +90,13 @@ func GetUserID(ctx context.Context) (int64, error) {
 	}
 	return userID, nil
 }
+
+// GetClaims returns the claims of the access token the request was
+// authenticated with.
+func GetClaims(ctx context.Context) (*models.AccessClaims, error) {
+	claims, ok := ctx.Value(ClaimsKey).(*models.AccessClaims)
+	if !ok {
+		return nil, fmt.Errorf("token claims not found in context")
+	}
+	return claims, nil
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/service"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// completeRetryDelays are the pauses between attempts to store a response.
+// The response has already been sent, so they only hold up the end of the
+// request, and are kept short.
+var completeRetryDelays = []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 250 * time.Millisecond}
+
+// IdempotencyMiddleware makes requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key is executed and its response
+// stored; repeats with the same body get the stored response back, while
+// reusing the key for a different request is rejected. The key is released
+// if the handler fails with a server error or panics, or if its response
+// cannot be stored, so that a retry runs the request again instead of being
+// told it is still in progress. It must run after
+// AuthMiddleware because keys are scoped per user.
+func IdempotencyMiddleware(idempotency service.IdempotencyService, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				respond.Error(w, "Idempotency key is too long", http.StatusBadRequest)
+				return
+			}
+
+			userID, err := GetUserID(r.Context())
+			if err != nil {
+				respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				respond.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				respond.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			record, replay, err := idempotency.Begin(r.Context(), userID, key, requestHash(r, body))
+			if err != nil {
+				respond.ServiceError(w, r, logger, err)
+				return
+			}
+
+			if replay {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(*record.ResponseStatus)
+				_, _ = w.Write(record.ResponseBody)
+				return
+			}
+
+			// The outcome must be recorded even if the client has gone away.
+			ctx := context.WithoutCancel(r.Context())
+			release := func() {
+				if err := idempotency.Release(ctx, record.ID); err != nil {
+					logger.ErrorContext(ctx, "Failed to release idempotency key", "key", key, "error", err)
+				}
+			}
+
+			// A panicking handler must not leave the key in progress; the
+			// panic is passed on to whatever recovers it.
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Server errors are not stored so that the client can retry them;
+			// the unit of work behind the handler has been rolled back.
+			if rec.status >= http.StatusInternalServerError {
+				release()
+				return
+			}
+
+			err = idempotency.Complete(ctx, record.ID, rec.status, rec.body.Bytes())
+			for _, delay := range completeRetryDelays {
+				if err == nil {
+					return
+				}
+				time.Sleep(delay)
+				err = idempotency.Complete(ctx, record.ID, rec.status, rec.body.Bytes())
+			}
+			if err != nil {
+				logger.ErrorContext(ctx, "Failed to store idempotent response; releasing the key", "key", key, "error", err)
+				release()
+			}
+		})
+	}
+}
+
+// requestHash fingerprints the method, path and body so that a key cannot be
+// replayed against a different operation.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{'\n'})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of the
+// status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/service"
+	"avito-shop/internal/test/backend"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyMiddleware_ReleasesKeyOnPanic(t *testing.T) {
+	repos, _ := backend.New(t)
+	user := &models.User{Username: "alice", PasswordHash: "hash", Coins: 1000, Role: models.RoleUser}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	calls := 0
+	handler := IdempotencyMiddleware(service.NewIdempotencyService(repos.Idempotency, 0), slog.Default())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				panic("handler failed")
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/sendCoin", strings.NewReader(`{"toUser":"bob","amount":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, user.ID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "handler failed" {
+				t.Errorf("recovered %v, want the handler's panic", p)
+			}
+		}()
+		send()
+	}()
+
+	if rr := send(); rr.Code != http.StatusOK {
+		t.Errorf("retry after panic: status %d, want %d", rr.Code, http.StatusOK)
+	}
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+}
+
+// failingKeys fails the first failures calls to SaveResponse.
+type failingKeys struct {
+	repository.IdempotencyRepository
+	failures int
+}
+
+func (k *failingKeys) SaveResponse(ctx context.Context, id int64, status int, body []byte) error {
+	if k.failures > 0 {
+		k.failures--
+		return errors.New("database unavailable")
+	}
+	return k.IdempotencyRepository.SaveResponse(ctx, id, status, body)
+}
+
+func TestIdempotencyMiddleware_SaveResponseFails(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		wantCalls  int
+		wantReplay bool
+	}{
+		{name: "Retried until stored", failures: len(completeRetryDelays), wantCalls: 1, wantReplay: true},
+		{name: "Released when never stored", failures: len(completeRetryDelays) + 1, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos, _ := backend.New(t)
+			user := &models.User{Username: "alice", PasswordHash: "hash", Coins: 1000, Role: models.RoleUser}
+			if err := repos.Users.Create(context.Background(), user); err != nil {
+				t.Fatalf("Failed to create user: %v", err)
+			}
+
+			keys := &failingKeys{IdempotencyRepository: repos.Idempotency, failures: tt.failures}
+			calls := 0
+			handler := IdempotencyMiddleware(service.NewIdempotencyService(keys, 0), slog.Default())(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					w.WriteHeader(http.StatusOK)
+				}),
+			)
+
+			send := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest("POST", "/api/sendCoin", strings.NewReader(`{"toUser":"bob","amount":1}`))
+				req.Header.Set(IdempotencyKeyHeader, "key-1")
+				req = req.WithContext(context.WithValue(req.Context(), UserIDKey, user.ID))
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				return rr
+			}
+
+			if rr := send(); rr.Code != http.StatusOK {
+				t.Fatalf("first request: status %d, want %d", rr.Code, http.StatusOK)
+			}
+
+			rr := send()
+			if rr.Code != http.StatusOK {
+				t.Errorf("retry: status %d, want %d", rr.Code, http.StatusOK)
+			}
+			if replayed := rr.Header().Get(IdempotentReplayedHeader) == "true"; replayed != tt.wantReplay {
+				t.Errorf("retry replayed = %v, want %v", replayed, tt.wantReplay)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
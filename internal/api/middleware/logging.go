@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"avito-shop/internal/logging"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestIDMiddleware tags every request with an ID, taken from the
+// X-Request-ID header when the client or a proxy supplied a usable one and
+// generated otherwise. The ID is echoed in the response and attached to
+// every record logged with the request context.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so that a
+// client cannot inject fields or line breaks into the log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestInfo collects what inner handlers learn about a request, such as
+// the route it matched and the authenticated user, for AccessLogMiddleware
+// and MetricsMiddleware to report once the response is written.
+type requestInfo struct {
+	route  string
+	userID int64
+}
+
+type requestInfoKey struct{}
+
+func getRequestInfo(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// withRequestInfo returns r with a requestInfo attached, reusing the one an
+// outer middleware attached already.
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info := getRequestInfo(r.Context()); info != nil {
+		return r, info
+	}
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+// Route records pattern as the route of the requests handled by next. The
+// router wraps every registered handler with it so that access logs and
+// metrics group requests by route rather than by raw path.
+func Route(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := getRequestInfo(r.Context()); info != nil {
+			info.route = pattern
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLogMiddleware logs one record per request with its method, route,
+// status, latency and, for authenticated requests, the user ID. Server
+// errors are logged at error level.
+func AccessLogMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, info := withRequestInfo(r)
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", info.route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if info.userID != 0 {
+				attrs = append(attrs, slog.Int64("user_id", info.userID))
+			}
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "HTTP request", attrs...)
+		})
+	}
+}
+
+// statusRecorder passes the response through while remembering its status.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"avito-shop/internal/logging"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{name: "Client supplied ID", header: "trace-42", keep: true},
+		{name: "Missing ID", header: ""},
+		{name: "ID with line break", header: "a\nb"},
+		{name: "ID with spaces", header: "a b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = logging.RequestID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			if seen == "" {
+				t.Fatal("Expected a request ID in the context")
+			}
+			if got := resp.Header().Get(RequestIDHeader); got != seen {
+				t.Errorf("Response header %s = %q, want %q", RequestIDHeader, got, seen)
+			}
+			if tt.keep != (seen == tt.header) {
+				t.Errorf("Request ID = %q, client sent %q, want kept = %v", seen, tt.header, tt.keep)
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/buy/", Route("/api/buy/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for AuthMiddleware, which reports the user the same way.
+		getRequestInfo(r.Context()).userID = 7
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})))
+	handler := RequestIDMiddleware(AccessLogMiddleware(logger)(mux))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/buy/cup", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record struct {
+		Method    string `json:"method"`
+		Route     string `json:"route"`
+		Path      string `json:"path"`
+		Status    int    `json:"status"`
+		Latency   *int64 `json:"latency"`
+		UserID    int64  `json:"user_id"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode access log %q: %v", buf.String(), err)
+	}
+
+	if record.Method != http.MethodGet || record.Route != "/api/buy/" || record.Path != "/api/buy/cup" {
+		t.Errorf("Logged request %s %s (route %q), want GET /api/buy/cup (route /api/buy/)", record.Method, record.Path, record.Route)
+	}
+	if record.Status != http.StatusUnprocessableEntity {
+		t.Errorf("Logged status %d, want %d", record.Status, http.StatusUnprocessableEntity)
+	}
+	if record.Latency == nil {
+		t.Error("Expected latency in the access log")
+	}
+	if record.UserID != 7 {
+		t.Errorf("Logged user ID %d, want 7", record.UserID)
+	}
+	if record.RequestID != "req-1" {
+		t.Errorf("Logged request ID %q, want %q", record.RequestID, "req-1")
+	}
+}
+
+func TestAccessLogMiddleware_ServerErrorLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	handler := AccessLogMiddleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var record struct {
+		Level string `json:"level"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode access log %q: %v", buf.String(), err)
+	}
+	if record.Level != slog.LevelError.String() {
+		t.Errorf("Logged level %q, want %q", record.Level, slog.LevelError.String())
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"avito-shop/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests no route matched, so that probing random
+// paths does not create a series per path.
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records the latency of every request in
+// metrics.HTTPRequestDuration, labelled with the route set by Route.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withRequestInfo(r)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := info.route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
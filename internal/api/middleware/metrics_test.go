@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"avito-shop/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/buy/", Route("/api/buy/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})))
+	handler := MetricsMiddleware(mux)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/buy/cup", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/path", nil))
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := resp.Body.String()
+
+	for _, want := range []string{
+		`avito_shop_http_request_duration_seconds_count{method="GET",route="/api/buy/",status="422"} 1`,
+		`avito_shop_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "/no/such/path") {
+		t.Error("Unmatched path leaked into metric labels")
+	}
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"avito-shop/internal/api/respond"
+	"avito-shop/internal/service"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit describes a token bucket: a client may make Burst requests at
+// once, after which the bucket refills at Rate requests per second. A zero
+// Rate means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// idleBucketTTL is how long a full bucket is kept before it is dropped; a
+// dropped bucket is indistinguishable from a full one.
+const idleBucketTTL = 10 * time.Minute
+
+// RateLimiter keeps one token bucket per client key.
+type RateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a limiter for limit, whose Rate must be positive.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &RateLimiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it
+// returns false and how long the client has to wait for the next token.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have been idle long enough to be full again, so
+// that memory does not grow with every client ever seen.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.limit.Burst) / l.limit.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if idle := now.Sub(b.updated); idle > idleBucketTTL && idle > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware rejects requests beyond limit with 429 Too Many
+// Requests, the error code rate_limited and a Retry-After header.
+// Authenticated requests are counted per user, so it must run after
+// AuthMiddleware on protected routes; other requests are counted per client
+// IP.
+func RateLimitMiddleware(limit RateLimit, logger *slog.Logger) func(http.Handler) http.Handler {
+	if limit.Rate <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	limiter := NewRateLimiter(limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + ClientIP(r)
+			if userID, err := GetUserID(r.Context()); err == nil {
+				key = "user:" + strconv.FormatInt(userID, 10)
+			}
+
+			if ok, wait := limiter.Allow(key); !ok {
+				limited := *service.ErrRateLimited
+				limited.RetryAfter = wait
+				respond.ServiceError(w, r, logger, &limited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the address the request came from. Forwarding headers are
+// ignored because any client can set them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+
+	ok, wait := limiter.Allow("a")
+	if ok {
+		t.Fatal("request beyond burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want %v", wait, 500*time.Millisecond)
+	}
+
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("other key was rejected")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Error("request after refill was rejected")
+	}
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("refill granted more than one token")
+	}
+
+	now = now.Add(time.Hour)
+	limiter.Allow("b")
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("idle bucket was not dropped")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RateLimitMiddleware(RateLimit{Rate: 1, Burst: 1}, slog.Default())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr string, userID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/info", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userID     int64
+		wantCode   int
+	}{
+		{name: "First request from IP", remoteAddr: "10.0.0.1:1234", wantCode: http.StatusOK},
+		{name: "Same IP, other port", remoteAddr: "10.0.0.1:5678", wantCode: http.StatusTooManyRequests},
+		{name: "Other IP", remoteAddr: "10.0.0.2:1234", wantCode: http.StatusOK},
+		{name: "User behind limited IP", remoteAddr: "10.0.0.1:1234", userID: 1, wantCode: http.StatusOK},
+		{name: "Same user from other IP", remoteAddr: "10.0.0.3:1234", userID: 1, wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(tt.remoteAddr, tt.userID)
+			if rr.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusTooManyRequests {
+				return
+			}
+			if rr.Header().Get("Retry-After") != "1" {
+				t.Errorf("Retry-After = %q, want %q", rr.Header().Get("Retry-After"), "1")
+			}
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || body.Code != "rate_limited" {
+				t.Errorf("error code = %q, %v, want rate_limited", body.Code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"avito-shop/internal/api/respond"
+	"context"
+	"net/http"
+)
+
+// RequireRole lets through only users whose token carries one of roles;
+// others get a JSON 403 with the code forbidden. It must run after
+// AuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]struct{}, len(roles))
+	for _, role := range roles {
+		allowed[role] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := GetUserID(r.Context()); err != nil {
+				respond.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if _, ok := allowed[GetUserRole(r.Context())]; !ok {
+				respond.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetUserRole returns the role from the token, or an empty string if the
+// request is unauthenticated.
+func GetUserRole(ctx context.Context) string {
+	role, _ := ctx.Value(UserRoleKey).(string)
+	return role
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	var handlerSpan trace.SpanContext
+	mux := http.NewServeMux()
+	mux.Handle("/api/buy/", Route("/api/buy/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	handler := TracingMiddleware(mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/buy/cup", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Recorded %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+
+	if span.Name() != "GET /api/buy/" {
+		t.Errorf("Span name = %q, want %q", span.Name(), "GET /api/buy/")
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Trace ID = %s, want the incoming %s", got, traceID)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("Parent span ID = %s, want the incoming 00f067aa0ba902b7", got)
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Span kind = %v, want server", span.SpanKind())
+	}
+	if handlerSpan.SpanID() != span.SpanContext().SpanID() {
+		t.Error("Handler context does not carry the server span")
+	}
+
+	wantAttrs := map[string]bool{
+		string(semconv.HTTPRouteKey):              false,
+		string(semconv.HTTPResponseStatusCodeKey): false,
+	}
+	for _, attr := range span.Attributes() {
+		switch string(attr.Key) {
+		case string(semconv.HTTPRouteKey):
+			wantAttrs[string(attr.Key)] = attr.Value.AsString() == "/api/buy/"
+		case string(semconv.HTTPResponseStatusCodeKey):
+			wantAttrs[string(attr.Key)] = attr.Value.AsInt64() == http.StatusNoContent
+		}
+	}
+	for key, ok := range wantAttrs {
+		if !ok {
+			t.Errorf("Span attribute %s is missing or wrong", key)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Package openapi serves the OpenAPI 3 description of the HTTP API and
+// validates request bodies against it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// spec is parsed once at start-up; the embedded document is checked by the
+// package tests, so a parse failure is a build defect.
+var spec = mustParse(document)
+
+// Document is the part of an OpenAPI document the validator needs.
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas   map[string]*Schema   `json:"schemas"`
+		Responses map[string]*Response `json:"responses"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Parse decodes an OpenAPI document and resolves the schema and response
+// references in it.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decoding OpenAPI document: %w", err)
+	}
+
+	resolved := make(map[*Schema]bool)
+	for _, schema := range doc.Components.Schemas {
+		if err := doc.resolve(schema, resolved); err != nil {
+			return nil, err
+		}
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if err := doc.resolveOperation(op, resolved); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+
+	return &doc, nil
+}
+
+func mustParse(data []byte) *Document {
+	doc, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// resolveOperation resolves the schemas of the request and response bodies
+// of op, replacing response references with the components they point to.
+func (d *Document) resolveOperation(op *Operation, resolved map[*Schema]bool) error {
+	if op.RequestBody != nil {
+		for _, media := range op.RequestBody.Content {
+			if err := d.resolve(media.Schema, resolved); err != nil {
+				return err
+			}
+		}
+	}
+
+	for status, resp := range op.Responses {
+		if resp.Ref != "" {
+			name, ok := strings.CutPrefix(resp.Ref, "#/components/responses/")
+			target := d.Components.Responses[name]
+			if !ok || target == nil {
+				return fmt.Errorf("unresolved response reference %q", resp.Ref)
+			}
+			resp = target
+			op.Responses[status] = target
+		}
+		for _, media := range resp.Content {
+			if err := d.resolve(media.Schema, resolved); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve replaces $ref schemas in the tree under s with the component they
+// point to.
+func (d *Document) resolve(s *Schema, resolved map[*Schema]bool) error {
+	if s == nil || resolved[s] {
+		return nil
+	}
+	resolved[s] = true
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		target := d.Components.Schemas[name]
+		if !ok || target == nil {
+			return fmt.Errorf("unresolved schema reference %q", s.Ref)
+		}
+		s.ref = target
+		return d.resolve(target, resolved)
+	}
+
+	for _, prop := range s.Properties {
+		if err := d.resolve(prop, resolved); err != nil {
+			return err
+		}
+	}
+	return d.resolve(s.Items, resolved)
+}
+
+// Operation returns the operation for method requests to path, where path is
+// a route pattern such as /api/v2/buy/{item}, or nil if it is not
+// documented.
+func (d *Document) Operation(method, path string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+// Routes lists the documented operations as "METHOD path" patterns, sorted.
+func (d *Document) Routes() []string {
+	var routes []string
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// BodySchema returns the schema of the JSON request body of o, or nil if o
+// takes no body.
+func (o *Operation) BodySchema() *Schema {
+	if o == nil || o.RequestBody == nil {
+		return nil
+	}
+	return o.RequestBody.Content["application/json"].Schema
+}
+
+// ResponseSchema returns the schema of the JSON body o documents for status,
+// or nil if there is none.
+func (o *Operation) ResponseSchema(status int) *Schema {
+	if o == nil || o.Responses[strconv.Itoa(status)] == nil {
+		return nil
+	}
+	return o.Responses[strconv.Itoa(status)].Content["application/json"].Schema
+}
+
+// Spec returns the API description embedded in the binary.
+func Spec() *Document {
+	return spec
+}
+
+// Handler serves the embedded document.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(document)
+	})
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse_UnresolvedReference(t *testing.T) {
+	doc := `{"components": {"schemas": {"A": {"$ref": "#/components/schemas/B"}}}}`
+	if _, err := Parse([]byte(doc)); err == nil {
+		t.Error("Parse() with a dangling $ref succeeded, want an error")
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := Spec().Operation("POST", "/api/v2/sendCoin").BodySchema()
+	if schema == nil {
+		t.Fatal("POST /api/v2/sendCoin has no body schema")
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{"Valid", `{"toUser": "bob", "amount": 10}`, nil},
+		{"Not an object", `"bob"`, []FieldError{{Message: "must be an object"}}},
+		{"Missing field", `{"toUser": "bob"}`, []FieldError{{Field: "amount", Message: "is required"}}},
+		{"Unknown field", `{"toUser": "bob", "amount": 10, "note": "hi"}`, []FieldError{{Field: "note", Message: "is not a known field"}}},
+		{"Wrong types", `{"toUser": 5, "amount": "10"}`, []FieldError{
+			{Field: "amount", Message: "must be an integer"},
+			{Field: "toUser", Message: "must be a string"},
+		}},
+		{"Fractional integer", `{"toUser": "bob", "amount": 1.5}`, []FieldError{{Field: "amount", Message: "must be an integer"}}},
+		{"Null", `{"toUser": null, "amount": 10}`, []FieldError{{Field: "toUser", Message: "must be a string, not null"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := json.NewDecoder(bytes.NewReader([]byte(tt.body)))
+			dec.UseNumber()
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				t.Fatalf("Failed to decode %s: %v", tt.body, err)
+			}
+
+			if got := schema.Validate(value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_ValidateNested(t *testing.T) {
+	schema := Spec().Components.Schemas["Info"]
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(
+		`{"coins": 10, "inventory": [{"type": "cup", "quantity": "2"}], "coinHistory": {"received": null, "sent": []}}`)))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	want := []FieldError{{Field: "inventory[0].quantity", Message: "must be an integer"}}
+	if got := schema.Validate(value); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %v, want %v", got, want)
+	}
+}
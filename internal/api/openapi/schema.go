@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema is the subset of the OpenAPI schema object the API uses.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []string           `json:"enum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+
+	// ref is the component Ref points to, set by Parse.
+	ref *Schema
+}
+
+// FieldError describes one value that does not match its schema. Field is
+// the path of the value, e.g. items[0].name, and is empty for the document
+// itself.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Validate checks value, as decoded by a json.Decoder with UseNumber, against
+// s and reports every mismatch it finds.
+func (s *Schema) Validate(value interface{}) []FieldError {
+	var errs []FieldError
+	s.validate(value, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(value interface{}, field string, errs *[]FieldError) {
+	if s.ref != nil {
+		s.ref.validate(value, field, errs)
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("must be %s, not null", article(s.Type))
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		s.validateObject(obj, field, errs)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				s.Items.validate(item, field+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		s.validateString(str, fail)
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			fail("must be an integer")
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			fail("must be an integer")
+			return
+		}
+		f, _ := n.Float64()
+		s.validateNumber(f, fail)
+	case "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			fail("must be a number")
+			return
+		}
+		f, _ := n.Float64()
+		s.validateNumber(f, fail)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]interface{}, field string, errs *[]FieldError) {
+	prefix := field
+	if prefix != "" {
+		prefix += "."
+	}
+
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, FieldError{Field: prefix + name, Message: "is required"})
+		}
+	}
+
+	// Sorted so that the errors come out in a stable order.
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = append(*errs, FieldError{Field: prefix + name, Message: "is not a known field"})
+			}
+			continue
+		}
+		prop.validate(obj[name], prefix+name, errs)
+	}
+}
+
+func (s *Schema) validateString(str string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(str)
+	if s.MinLength != nil && length < *s.MinLength {
+		fail("must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		fail("must be at most %d characters long", *s.MaxLength)
+	}
+	if len(s.Enum) > 0 && !contains(s.Enum, str) {
+		fail("must be one of %v", s.Enum)
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			fail("must be an RFC 3339 timestamp")
+		}
+	}
+}
+
+func (s *Schema) validateNumber(f float64, fail func(string, ...interface{})) {
+	if s.Minimum != nil && f < *s.Minimum {
+		fail("must be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		fail("must be at most %v", *s.Maximum)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func article(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	}
+	return "a " + typ
+}
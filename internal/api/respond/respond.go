@@ -0,0 +1,73 @@
+// Package respond writes the JSON responses of the API, including the error
+// body shared by the handlers and the middleware.
+package respond
+
+import (
+	"avito-shop/internal/api/openapi"
+	"avito-shop/internal/service"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrorBody carries a human-readable message in Errors and a stable
+// machine-readable Code clients can branch on. Details lists the schema
+// violations of a rejected request body.
+type ErrorBody struct {
+	Errors  string               `json:"errors"`
+	Code    string               `json:"code"`
+	Details []openapi.FieldError `json:"details,omitempty"`
+}
+
+// statusByKind maps service error kinds to response status codes. Unknown
+// kinds are reported as internal errors.
+var statusByKind = map[service.Kind]int{
+	service.KindValidation:        http.StatusBadRequest,
+	service.KindNotFound:          http.StatusNotFound,
+	service.KindConflict:          http.StatusConflict,
+	service.KindInsufficientFunds: http.StatusUnprocessableEntity,
+	service.KindUnauthorized:      http.StatusUnauthorized,
+	service.KindRateLimited:       http.StatusTooManyRequests,
+	service.KindUnprocessable:     http.StatusUnprocessableEntity,
+}
+
+func JSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Error reports a failure detected by the transport itself. Its code is
+// derived from the status, e.g. "bad_request"; service errors go through
+// ServiceError instead.
+func Error(w http.ResponseWriter, message string, status int) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	JSON(w, ErrorBody{Errors: message, Code: code}, status)
+}
+
+// ServiceError reports an error returned by a service. Errors the client
+// may see are written with their code and message; anything else is logged
+// and answered with a generic 500 so that internal details such as database
+// errors do not leak.
+func ServiceError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	var serviceErr *service.Error
+	if errors.As(err, &serviceErr) {
+		if status, ok := statusByKind[serviceErr.Kind]; ok {
+			if serviceErr.RetryAfter > 0 {
+				seconds := int(math.Ceil(serviceErr.RetryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			}
+			JSON(w, ErrorBody{Errors: serviceErr.Message, Code: serviceErr.Code}, status)
+			return
+		}
+	}
+
+	logger.ErrorContext(r.Context(), "Internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+	Error(w, "Internal server error", http.StatusInternalServerError)
+}
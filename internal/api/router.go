@@ -3,31 +3,149 @@ package api
 import (
 	"avito-shop/internal/api/handlers"
 	"avito-shop/internal/api/middleware"
+	"avito-shop/internal/api/openapi"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"avito-shop/internal/service"
+	"context"
+	"log/slog"
 	"net/http"
 )
 
+// RouterConfig holds the HTTP-level settings that are not part of any
+// service.
+type RouterConfig struct {
+	// LegacyAutoRegister makes /api/auth register unknown usernames instead
+	// of rejecting them.
+	LegacyAutoRegister bool
+	RateLimits         RateLimits
+	// Logger receives access logs and errors; nil means slog.Default().
+	Logger *slog.Logger
+	// Readiness backs /readyz; nil means always ready.
+	Readiness handlers.ReadinessCheck
+}
+
+// RateLimits are applied per route, so a client exhausting the limit of one
+// endpoint can still use the others. A zero RateLimit disables limiting.
+type RateLimits struct {
+	// Auth limits each client IP on the login, registration and token
+	// endpoints.
+	Auth middleware.RateLimit
+	// Write limits each user on the endpoints that spend coins.
+	Write middleware.RateLimit
+	// Read limits each user on the remaining authenticated endpoints.
+	Read middleware.RateLimit
+}
+
 type Router struct {
 	services *service.Services
+	config   RouterConfig
+	logger   *slog.Logger
 	mux      *http.ServeMux
+	// routes lists the registered "METHOD path" patterns.
+	routes []string
 }
 
-func NewRouter(services *service.Services) *Router {
+func NewRouter(services *service.Services, config RouterConfig) *Router {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &Router{
 		services: services,
+		config:   config,
+		logger:   logger,
 		mux:      http.NewServeMux(),
 	}
 }
 
+// handle registers handler for method requests to path behind middlewares,
+// outermost first, labelling the requests with path in access logs, metrics
+// and traces. The request body is validated against the OpenAPI document
+// right before handler runs, so authentication and rate limiting come
+// first. Routes that share a middleware value share its state, such as a
+// rate limiter.
+func (r *Router) handle(method, path string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) {
+	pattern := method + " " + path
+	r.routes = append(r.routes, pattern)
+	handler = handlers.ValidateRequest(openapi.Spec().Operation(method, path))(handler)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	r.mux.Handle(pattern, middleware.Route(path, handler))
+}
+
+// Setup registers every route under /api/v2 and, for existing clients,
+// under its original /api path. Both paths share one handler and one set of
+// middlewares, and so one rate limiter.
 func (r *Router) Setup() http.Handler {
-	r.mux.Handle("/api/auth", handlers.NewAuthHandler(r.services.Users))
+	limits := r.config.RateLimits
+	auth := middleware.AuthMiddleware(r.services.Auth, r.logger)
+	idempotency := middleware.IdempotencyMiddleware(r.services.Idempotency, r.logger)
 
-	r.mux.Handle("/api/info", middleware.AuthMiddleware(r.services.TokenSecret)(
-		handlers.NewInfoHandler(r.services.Info)))
-	r.mux.Handle("/api/sendCoin", middleware.AuthMiddleware(r.services.TokenSecret)(
-		handlers.NewTransferHandler(r.services.Users)))
-	r.mux.Handle("/api/buy/", middleware.AuthMiddleware(r.services.TokenSecret)(
-		handlers.NewBuyHandler(r.services.Merchandise)))
+	registerHandler := handlers.NewRegisterHandler(r.services.Users, r.services.Auth, r.logger)
+	registerLimit := middleware.RateLimitMiddleware(limits.Auth, r.logger)
+	r.handle(http.MethodPost, "/api/register", registerHandler, registerLimit)
+	r.handle(http.MethodPost, "/api/v2/auth/register", registerHandler, registerLimit)
+	loginHandler := handlers.NewLoginHandler(r.services.Auth, r.logger)
+	loginLimit := middleware.RateLimitMiddleware(limits.Auth, r.logger)
+	r.handle(http.MethodPost, "/api/login", loginHandler, loginLimit)
+	r.handle(http.MethodPost, "/api/v2/auth/login", loginHandler, loginLimit)
+	r.handle(http.MethodPost, "/api/auth",
+		handlers.NewAuthHandler(r.services.Users, r.services.Auth, r.config.LegacyAutoRegister, r.logger),
+		middleware.RateLimitMiddleware(limits.Auth, r.logger))
+	refreshHandler := handlers.NewRefreshHandler(r.services.Auth, r.logger)
+	refreshLimit := middleware.RateLimitMiddleware(limits.Auth, r.logger)
+	r.handle(http.MethodPost, "/api/auth/refresh", refreshHandler, refreshLimit)
+	r.handle(http.MethodPost, "/api/v2/auth/refresh", refreshHandler, refreshLimit)
+	logoutHandler := handlers.NewLogoutHandler(r.services.Auth, r.logger)
+	logoutLimit := middleware.RateLimitMiddleware(limits.Read, r.logger)
+	r.handle(http.MethodPost, "/api/auth/logout", logoutHandler, auth, logoutLimit)
+	r.handle(http.MethodPost, "/api/v2/auth/logout", logoutHandler, auth, logoutLimit)
+
+	infoHandler := handlers.NewInfoHandler(r.services.Info, r.logger)
+	infoLimit := middleware.RateLimitMiddleware(limits.Read, r.logger)
+	r.handle(http.MethodGet, "/api/info", infoHandler, auth, infoLimit)
+	r.handle(http.MethodGet, "/api/v2/info", infoHandler, auth, infoLimit)
+	merchHandler := handlers.NewMerchHandler(r.services.Merchandise, r.logger)
+	merchLimit := middleware.RateLimitMiddleware(limits.Read, r.logger)
+	for _, prefix := range []string{"/api", "/api/v2"} {
+		r.handle(http.MethodGet, prefix+"/merch", merchHandler, auth, merchLimit)
+		r.handle(http.MethodGet, prefix+"/merch/{name}", merchHandler, auth, merchLimit)
+	}
+	adminMerchHandler := handlers.NewAdminMerchHandler(r.services.Merchandise, r.logger)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	for _, prefix := range []string{"/api", "/api/v2"} {
+		r.handle(http.MethodGet, prefix+"/admin/merch", adminMerchHandler, auth, adminOnly)
+		r.handle(http.MethodPost, prefix+"/admin/merch", adminMerchHandler, auth, adminOnly)
+		r.handle(http.MethodPatch, prefix+"/admin/merch/{name}", adminMerchHandler, auth, adminOnly)
+		r.handle(http.MethodDelete, prefix+"/admin/merch/{name}", adminMerchHandler, auth, adminOnly)
+	}
+	transactionsHandler := handlers.NewTransactionsHandler(r.services.Transactions, r.logger)
+	transactionsLimit := middleware.RateLimitMiddleware(limits.Read, r.logger)
+	r.handle(http.MethodGet, "/api/transactions", transactionsHandler, auth, transactionsLimit)
+	r.handle(http.MethodGet, "/api/v2/transactions", transactionsHandler, auth, transactionsLimit)
+	transferHandler := handlers.NewTransferHandler(r.services.Users, r.logger)
+	transferLimit := middleware.RateLimitMiddleware(limits.Write, r.logger)
+	r.handle(http.MethodPost, "/api/sendCoin", transferHandler, auth, transferLimit, idempotency)
+	r.handle(http.MethodPost, "/api/v2/sendCoin", transferHandler, auth, transferLimit, idempotency)
+	buyHandler := handlers.NewBuyHandler(r.services.Merchandise, r.logger)
+	buyLimit := middleware.RateLimitMiddleware(limits.Write, r.logger)
+	// Buying changes state, so v2 takes POST; v1 keeps its original GET.
+	r.handle(http.MethodGet, "/api/buy/{item}", buyHandler, auth, buyLimit, idempotency)
+	r.handle(http.MethodPost, "/api/v2/buy/{item}", buyHandler, auth, buyLimit, idempotency)
+
+	readiness := r.config.Readiness
+	if readiness == nil {
+		readiness = func(context.Context) error { return nil }
+	}
+	r.handle(http.MethodGet, "/healthz", handlers.NewHealthHandler())
+	r.handle(http.MethodGet, "/readyz", handlers.NewReadinessHandler(readiness, r.logger))
+	r.handle(http.MethodGet, "/metrics", metrics.Handler())
+	r.handle(http.MethodGet, "/api/openapi.json", openapi.Handler())
 
-	return r.mux
+	return middleware.RequestIDMiddleware(middleware.TracingMiddleware(
+		middleware.AccessLogMiddleware(r.logger)(middleware.MetricsMiddleware(
+			handlers.WithRoutingErrors(r.mux)))))
 }
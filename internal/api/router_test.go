@@ -0,0 +1,181 @@
+package api
+
+import (
+	"avito-shop/internal/api/openapi"
+	"avito-shop/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var notReady error
+	handler := NewRouter(&service.Services{}, RouterConfig{
+		Readiness: func(context.Context) error { return notReady },
+	}).Setup()
+
+	get := func(path string) int {
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		return resp.Code
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want %d", code, http.StatusOK)
+	}
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("GET /readyz while ready = %d, want %d", code, http.StatusOK)
+	}
+
+	notReady = errors.New("database is down")
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz while not ready = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("GET /healthz while not ready = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestRoutingErrors(t *testing.T) {
+	handler := NewRouter(&service.Services{}, RouterConfig{}).Setup()
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantErr   string
+		wantAllow string
+	}{
+		{"Unknown path", http.MethodGet, "/api/v2/unknown", http.StatusNotFound, "not_found", ""},
+		{"Buy without item", http.MethodPost, "/api/v2/buy/", http.StatusNotFound, "not_found", ""},
+		{"Wrong method", http.MethodDelete, "/healthz", http.StatusMethodNotAllowed, "method_not_allowed", "GET, HEAD"},
+		{"Buy with GET on v2", http.MethodGet, "/api/v2/buy/cup", http.StatusMethodNotAllowed, "method_not_allowed", "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if resp.Code != tt.wantCode {
+				t.Errorf("Status = %d, want %d", resp.Code, tt.wantCode)
+			}
+			if got := resp.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := resp.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if body.Code != tt.wantErr {
+				t.Errorf("Error code = %q, want %q", body.Code, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	router := NewRouter(&service.Services{}, RouterConfig{})
+	router.Setup()
+
+	registered := append([]string(nil), router.routes...)
+	sort.Strings(registered)
+	documented := openapi.Spec().Routes()
+
+	if !reflect.DeepEqual(registered, documented) {
+		t.Errorf("Registered routes differ from the OpenAPI document\nregistered: %v\ndocumented: %v", registered, documented)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	handler := NewRouter(&service.Services{}, RouterConfig{}).Setup()
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json = %d, want %d", resp.Code, http.StatusOK)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want a 3.x version", doc.OpenAPI)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	ts := setupTestServer(t)
+	ctx := context.Background()
+	if err := ts.services.Users.Register(ctx, "alice", "testpass"); err != nil {
+		t.Fatalf("Failed to register user: %v", err)
+	}
+	pair, err := ts.services.Auth.Login(ctx, "alice", "testpass", "")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		token       string
+		body        string
+		wantCode    int
+		wantDetails []string
+	}{
+		{"Unknown field", "/api/v2/sendCoin", pair.AccessToken, `{"toUser": "bob", "amount": 10, "memo": "hi"}`, http.StatusBadRequest, []string{"memo"}},
+		{"Wrong type", "/api/sendCoin", pair.AccessToken, `{"toUser": "bob", "amount": "10"}`, http.StatusBadRequest, []string{"amount"}},
+		// Authentication comes before validation.
+		{"Unauthenticated", "/api/v2/sendCoin", "", `{"memo": "hi"}`, http.StatusUnauthorized, nil},
+		{"Missing fields", "/api/v2/auth/login", "", `{}`, http.StatusBadRequest, []string{"username", "password"}},
+		{"Empty body", "/api/v2/auth/refresh", "", ``, http.StatusBadRequest, []string{""}},
+		{"Trailing data", "/api/v2/auth/login", "", `{"username": "a", "password": "b"} {}`, http.StatusBadRequest, []string{""}},
+		{"Oversized body", "/api/v2/auth/login", "", `{"username": "` + strings.Repeat("a", 1<<20) + `", "password": "b"}`, http.StatusRequestEntityTooLarge, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp := ts.executeRequest(req)
+
+			if resp.Code != tt.wantCode {
+				t.Fatalf("Status = %d, want %d; body %s", resp.Code, tt.wantCode, resp.Body)
+			}
+
+			var body struct {
+				Details []struct {
+					Field string `json:"field"`
+				} `json:"details"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			var fields []string
+			for _, detail := range body.Details {
+				fields = append(fields, detail.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantDetails) {
+				t.Errorf("Detail fields = %q, want %q", fields, tt.wantDetails)
+			}
+		})
+	}
+}
@@ -1,45 +1,591 @@
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"avito-shop/internal/logging"
+	"avito-shop/internal/tracing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDev        = "dev"
+	EnvProduction = "production"
+
+	DriverPostgres = "postgres"
+	// DriverSQLite stores data in the single file named by DatabaseConfig.Path.
+	DriverSQLite = "sqlite"
+	// DriverMemory keeps all data in process memory; it is lost on restart.
+	DriverMemory = "memory"
+
+	// DefaultJWTSecret is only accepted in the dev environment.
+	DefaultJWTSecret = "your-secret-key"
+)
+
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Env            string               `yaml:"env"`
+	Server         ServerConfig         `yaml:"server"`
+	Log            LogConfig            `yaml:"log"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	Database       DatabaseConfig       `yaml:"database"`
+	JWT            JWTConfig            `yaml:"jwt"`
+	Auth           AuthConfig           `yaml:"auth"`
+	RateLimit      RateLimitConfig      `yaml:"rate_limit"`
+	Idempotency    IdempotencyConfig    `yaml:"idempotency"`
+	Reconciliation ReconciliationConfig `yaml:"reconciliation"`
+	Admin          AdminConfig          `yaml:"admin"`
 }
 
 type ServerConfig struct {
-	Port string
+	Port string `yaml:"port"`
+	// ShutdownTimeout is how long in-flight requests may run after SIGTERM
+	// before their connections are closed.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// ShutdownDelay is how long the server keeps serving after SIGTERM with
+	// /readyz failing, so that load balancers stop routing to it before the
+	// listener is closed.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+}
+
+// LogConfig selects the minimum level (debug, info, warn or error) and the
+// output format (json or text) of the service log.
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// SlogLevel returns Level as a slog.Level.
+func (c LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.Level))
+	return level, err
+}
+
+// TracingConfig selects where OpenTelemetry spans go: nowhere ("none"),
+// stdout, or an OTLP/HTTP collector at OTLPEndpoint (host:port).
+type TracingConfig struct {
+	Exporter     string `yaml:"exporter"`
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Driver       string `yaml:"driver"`
+	Path         string `yaml:"path"`
+	Host         string `yaml:"host"`
+	Port         string `yaml:"port"`
+	User         string `yaml:"user"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	DBName       string `yaml:"name"`
+	SSLMode      string `yaml:"sslmode"`
+	// AutoMigrate applies pending schema migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate"`
 }
 
 type JWTConfig struct {
-	SecretKey string
-	ExpiresIn int64
+	SecretKey     string `yaml:"secret_key"`
+	SecretKeyFile string `yaml:"secret_key_file"`
+	// AccessTokenTTL is the lifetime of access tokens; clients renew them
+	// with a refresh token, which lives for RefreshTokenTTL.
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
 }
 
-func LoadConfig() (*Config, error) {
+type AuthConfig struct {
+	// LegacyAutoRegister keeps the original behaviour of /api/auth, which
+	// registers unknown usernames instead of rejecting them. New clients
+	// should use /api/register and /api/login.
+	LegacyAutoRegister bool          `yaml:"legacy_auto_register"`
+	Lockout            LockoutConfig `yaml:"lockout"`
+}
+
+// LockoutConfig throttles password guessing. A username or client IP may
+// fail MaxUserAttempts or MaxIPAttempts times within Window; every further
+// failure locks it out for BaseDelay, doubling up to MaxDelay. Zero attempts
+// disable the limit.
+type LockoutConfig struct {
+	MaxUserAttempts int           `yaml:"max_user_attempts"`
+	MaxIPAttempts   int           `yaml:"max_ip_attempts"`
+	BaseDelay       time.Duration `yaml:"base_delay"`
+	MaxDelay        time.Duration `yaml:"max_delay"`
+	Window          time.Duration `yaml:"window"`
+}
+
+// RateLimitConfig limits how often a client may call the API. Auth applies
+// per client IP to the login, registration and token endpoints, Write per
+// user to the endpoints that spend coins and Read per user to the rest.
+type RateLimitConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Auth    RateLimitRule `yaml:"auth"`
+	Write   RateLimitRule `yaml:"write"`
+	Read    RateLimitRule `yaml:"read"`
+}
+
+// RateLimitRule is a token bucket: Burst requests at once, refilled at RPS
+// requests per second. An RPS of zero disables the rule.
+type RateLimitRule struct {
+	RPS   float64 `yaml:"rps"`
+	Burst int     `yaml:"burst"`
+}
+
+// IdempotencyConfig sets how long an Idempotency-Key is remembered. After
+// TTL the key may be reused, which also frees keys left in progress by a
+// crashed request.
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type ReconciliationConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	AutoFix  bool          `yaml:"auto_fix"`
+}
+
+// AdminConfig lists the administrators. At start-up the server grants the
+// admin role to the listed accounts that exist and revokes it from everyone
+// else.
+type AdminConfig struct {
+	Usernames []string `yaml:"usernames"`
+}
+
+func defaultConfig() *Config {
 	return &Config{
+		Env: EnvProduction,
 		Server: ServerConfig{
-			Port: "8080",
+			Port:            "8080",
+			ShutdownTimeout: 30 * time.Second,
+			ShutdownDelay:   5 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: logging.FormatJSON,
+		},
+		Tracing: TracingConfig{
+			Exporter:     tracing.ExporterNone,
+			OTLPEndpoint: "localhost:4318",
 		},
 		Database: DatabaseConfig{
-			Host:     "localhost",
-			Port:     "5432",
-			User:     "postgres",
-			Password: "19770811",
-			DBName:   "avito_shop",
-			SSLMode:  "disable",
+			Driver:      DriverPostgres,
+			Path:        "avito_shop.db",
+			Host:        "localhost",
+			Port:        "5432",
+			User:        "postgres",
+			DBName:      "avito_shop",
+			SSLMode:     "disable",
+			AutoMigrate: true,
 		},
 		JWT: JWTConfig{
-			SecretKey: "your-secret-key",
-			ExpiresIn: 24,
+			SecretKey:       DefaultJWTSecret,
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		Auth: AuthConfig{
+			LegacyAutoRegister: true,
+			Lockout: LockoutConfig{
+				MaxUserAttempts: 5,
+				MaxIPAttempts:   50,
+				BaseDelay:       time.Second,
+				MaxDelay:        15 * time.Minute,
+				Window:          time.Hour,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Auth:    RateLimitRule{RPS: 0.2, Burst: 10},
+			Write:   RateLimitRule{RPS: 5, Burst: 20},
+			Read:    RateLimitRule{RPS: 20, Burst: 50},
 		},
-	}, nil
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		Reconciliation: ReconciliationConfig{
+			Enabled:  true,
+			Interval: time.Hour,
+			AutoFix:  false,
+		},
+	}
+}
+
+// LoadConfig loads the configuration from the process arguments and
+// environment. See Load.
+func LoadConfig() (*Config, error) {
+	return Load(flag.CommandLine, os.Args[1:])
+}
+
+// Load builds the configuration in layers, each overriding the previous one:
+// built-in defaults, the YAML or JSON file named by -config or CONFIG_FILE,
+// environment variables and finally command-line flags. Secrets can be read
+// from files via the *_FILE variables or flags. The configuration flags are
+// registered on fs, so callers may add their own flags before calling Load.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg, err := load(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// LoadDatabase loads the configuration like Load but validates only the
+// database section. It is meant for offline commands that never serve
+// requests or handle tokens, so that they do not need the server's secrets.
+func LoadDatabase(fs *flag.FlagSet, args []string) (*DatabaseConfig, error) {
+	cfg, err := load(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg.Database, nil
+}
+
+func load(fs *flag.FlagSet, args []string) (*Config, error) {
+	flags := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+
+	path := os.Getenv("CONFIG_FILE")
+	if *flags.configFile != "" {
+		path = *flags.configFile
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	flags.apply(fs, cfg)
+
+	if err := resolveSecrets(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so one decoder handles both formats.
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	fields := map[string]*string{
+		"APP_ENV":               &cfg.Env,
+		"SERVER_PORT":           &cfg.Server.Port,
+		"LOG_LEVEL":             &cfg.Log.Level,
+		"LOG_FORMAT":            &cfg.Log.Format,
+		"TRACING_EXPORTER":      &cfg.Tracing.Exporter,
+		"TRACING_OTLP_ENDPOINT": &cfg.Tracing.OTLPEndpoint,
+		"DB_DRIVER":             &cfg.Database.Driver,
+		"DB_PATH":               &cfg.Database.Path,
+		"DB_HOST":               &cfg.Database.Host,
+		"DB_PORT":               &cfg.Database.Port,
+		"DB_USER":               &cfg.Database.User,
+		"DB_PASSWORD":           &cfg.Database.Password,
+		"DB_PASSWORD_FILE":      &cfg.Database.PasswordFile,
+		"DB_NAME":               &cfg.Database.DBName,
+		"DB_SSLMODE":            &cfg.Database.SSLMode,
+		"JWT_SECRET":            &cfg.JWT.SecretKey,
+		"JWT_SECRET_FILE":       &cfg.JWT.SecretKeyFile,
+	}
+	for name, field := range fields {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+
+	bools := map[string]*bool{
+		"DB_AUTO_MIGRATE":           &cfg.Database.AutoMigrate,
+		"AUTH_LEGACY_AUTO_REGISTER": &cfg.Auth.LegacyAutoRegister,
+		"RATE_LIMIT_ENABLED":        &cfg.RateLimit.Enabled,
+		"RECONCILIATION_ENABLED":    &cfg.Reconciliation.Enabled,
+		"RECONCILIATION_AUTO_FIX":   &cfg.Reconciliation.AutoFix,
+	}
+	for name, field := range bools {
+		if value, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field = b
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"JWT_ACCESS_TOKEN_TTL":    &cfg.JWT.AccessTokenTTL,
+		"JWT_REFRESH_TOKEN_TTL":   &cfg.JWT.RefreshTokenTTL,
+		"RECONCILIATION_INTERVAL": &cfg.Reconciliation.Interval,
+		"SERVER_SHUTDOWN_TIMEOUT": &cfg.Server.ShutdownTimeout,
+		"SERVER_SHUTDOWN_DELAY":   &cfg.Server.ShutdownDelay,
+		"IDEMPOTENCY_TTL":         &cfg.Idempotency.TTL,
+	}
+	for name, field := range durations {
+		if value, ok := lookup(name); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*field = d
+		}
+	}
+
+	if value, ok := lookup("ADMIN_USERNAMES"); ok {
+		cfg.Admin.Usernames = splitList(value)
+	}
+
+	return nil
+}
+
+type flagValues struct {
+	configFile     *string
+	env            *string
+	port           *string
+	dbDriver       *string
+	dbPath         *string
+	dbHost         *string
+	dbPort         *string
+	dbUser         *string
+	dbName         *string
+	dbSSLMode      *string
+	dbPasswordFile *string
+	jwtSecretFile  *string
+}
+
+// registerFlags defines the configuration flags. Secrets themselves cannot be
+// passed as flags because arguments are visible to other processes.
+func registerFlags(fs *flag.FlagSet) *flagValues {
+	return &flagValues{
+		configFile:     fs.String("config", "", "path to a YAML or JSON config file"),
+		env:            fs.String("env", "", "environment: dev or production"),
+		port:           fs.String("port", "", "HTTP port to listen on"),
+		dbDriver:       fs.String("db-driver", "", "storage backend: postgres, sqlite or memory"),
+		dbPath:         fs.String("db-path", "", "SQLite database file"),
+		dbHost:         fs.String("db-host", "", "database host"),
+		dbPort:         fs.String("db-port", "", "database port"),
+		dbUser:         fs.String("db-user", "", "database user"),
+		dbName:         fs.String("db-name", "", "database name"),
+		dbSSLMode:      fs.String("db-sslmode", "", "database SSL mode"),
+		dbPasswordFile: fs.String("db-password-file", "", "file containing the database password"),
+		jwtSecretFile:  fs.String("jwt-secret-file", "", "file containing the JWT signing secret"),
+	}
+}
+
+// apply copies the flags that were set explicitly on the command line.
+func (f *flagValues) apply(fs *flag.FlagSet, cfg *Config) {
+	fields := map[string]struct {
+		value  *string
+		target *string
+	}{
+		"env":              {f.env, &cfg.Env},
+		"port":             {f.port, &cfg.Server.Port},
+		"db-driver":        {f.dbDriver, &cfg.Database.Driver},
+		"db-path":          {f.dbPath, &cfg.Database.Path},
+		"db-host":          {f.dbHost, &cfg.Database.Host},
+		"db-port":          {f.dbPort, &cfg.Database.Port},
+		"db-user":          {f.dbUser, &cfg.Database.User},
+		"db-name":          {f.dbName, &cfg.Database.DBName},
+		"db-sslmode":       {f.dbSSLMode, &cfg.Database.SSLMode},
+		"db-password-file": {f.dbPasswordFile, &cfg.Database.PasswordFile},
+		"jwt-secret-file":  {f.jwtSecretFile, &cfg.JWT.SecretKeyFile},
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		if field, ok := fields[fl.Name]; ok {
+			*field.target = *field.value
+		}
+	})
+}
+
+// resolveSecrets replaces secrets with the contents of their *_file
+// counterpart when one is configured.
+func resolveSecrets(cfg *Config) error {
+	secrets := []struct {
+		path  string
+		value *string
+	}{
+		{cfg.Database.PasswordFile, &cfg.Database.Password},
+		{cfg.JWT.SecretKeyFile, &cfg.JWT.SecretKey},
+	}
+
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(secret.path)
+		if err != nil {
+			return fmt.Errorf("error reading secret file: %w", err)
+		}
+		*secret.value = strings.TrimRight(string(data), "\r\n")
+	}
+
+	return nil
+}
+
+// Validate reports every missing or unsafe setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Env != EnvDev && c.Env != EnvProduction {
+		errs = append(errs, fmt.Errorf("env must be %q or %q, got %q", EnvDev, EnvProduction, c.Env))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server port %q is invalid", c.Server.Port))
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server shutdown timeout must be positive"))
+	}
+
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("server shutdown delay must not be negative"))
+	}
+
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("log level %q is invalid", c.Log.Level))
+	}
+	if c.Log.Format != logging.FormatJSON && c.Log.Format != logging.FormatText {
+		errs = append(errs, fmt.Errorf("log format must be %q or %q, got %q", logging.FormatJSON, logging.FormatText, c.Log.Format))
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if c.Tracing.OTLPEndpoint == "" {
+			errs = append(errs, fmt.Errorf("OTLP endpoint is required for the %q trace exporter", tracing.ExporterOTLP))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("trace exporter must be %q, %q or %q, got %q",
+			tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP, c.Tracing.Exporter))
+	}
+
+	if c.JWT.SecretKey == "" {
+		errs = append(errs, fmt.Errorf("JWT secret is required"))
+	}
+
+	errs = append(errs, c.Database.validate()...)
+
+	if c.Env != EnvDev && c.JWT.SecretKey == DefaultJWTSecret {
+		errs = append(errs, fmt.Errorf("the default JWT secret may only be used with env %q; set JWT_SECRET or JWT_SECRET_FILE", EnvDev))
+	}
+
+	if c.JWT.AccessTokenTTL <= 0 || c.JWT.RefreshTokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("JWT token lifetimes must be positive"))
+	}
+	if c.JWT.AccessTokenTTL >= c.JWT.RefreshTokenTTL {
+		errs = append(errs, fmt.Errorf("access token lifetime must be shorter than the refresh token lifetime"))
+	}
+
+	lockout := c.Auth.Lockout
+	if lockout.MaxUserAttempts < 0 || lockout.MaxIPAttempts < 0 {
+		errs = append(errs, fmt.Errorf("login lockout attempt limits must not be negative"))
+	}
+	if (lockout.MaxUserAttempts > 0 || lockout.MaxIPAttempts > 0) &&
+		(lockout.BaseDelay <= 0 || lockout.MaxDelay < lockout.BaseDelay || lockout.Window <= 0) {
+		errs = append(errs, fmt.Errorf("login lockout needs a positive base delay and window and a max delay of at least the base delay"))
+	}
+
+	rules := map[string]RateLimitRule{
+		"auth":  c.RateLimit.Auth,
+		"write": c.RateLimit.Write,
+		"read":  c.RateLimit.Read,
+	}
+	for name, rule := range rules {
+		if rule.RPS < 0 || (rule.RPS > 0 && rule.Burst < 1) {
+			errs = append(errs, fmt.Errorf("%s rate limit needs a non-negative rps and a positive burst", name))
+		}
+	}
+
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("idempotency TTL must be positive"))
+	}
+
+	if c.Reconciliation.Enabled && c.Reconciliation.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("reconciliation interval must be positive"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Validate reports every missing setting of the selected database driver.
+func (c *DatabaseConfig) Validate() error {
+	if errs := c.validate(); len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *DatabaseConfig) validate() []error {
+	type setting struct {
+		name  string
+		value string
+	}
+	var required []setting
+
+	switch c.Driver {
+	case DriverPostgres:
+		required = append(required,
+			setting{"database host", c.Host},
+			setting{"database port", c.Port},
+			setting{"database user", c.User},
+			setting{"database name", c.DBName},
+		)
+	case DriverSQLite:
+		required = append(required, setting{"database path", c.Path})
+	case DriverMemory:
+	default:
+		return []error{fmt.Errorf("database driver must be %q, %q or %q, got %q", DriverPostgres, DriverSQLite, DriverMemory, c.Driver)}
+	}
+
+	var errs []error
+	for _, field := range required {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", field.name))
+		}
+	}
+	return errs
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
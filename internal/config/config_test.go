@@ -0,0 +1,265 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadForTest(t *testing.T, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+	setEnvForTest(t, env)
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+// setEnvForTest clears every variable the loader reads and then sets env.
+func setEnvForTest(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{
+		"CONFIG_FILE", "APP_ENV", "SERVER_PORT", "LOG_LEVEL", "LOG_FORMAT", "TRACING_EXPORTER", "TRACING_OTLP_ENDPOINT", "SERVER_SHUTDOWN_TIMEOUT", "SERVER_SHUTDOWN_DELAY", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD",
+		"DB_PASSWORD_FILE", "DB_NAME", "DB_SSLMODE", "JWT_SECRET", "JWT_SECRET_FILE", "JWT_ACCESS_TOKEN_TTL", "JWT_REFRESH_TOKEN_TTL",
+		"AUTH_LEGACY_AUTO_REGISTER", "RATE_LIMIT_ENABLED", "RECONCILIATION_ENABLED", "RECONCILIATION_INTERVAL", "RECONCILIATION_AUTO_FIX", "ADMIN_USERNAMES",
+		"IDEMPOTENCY_TTL", "DB_DRIVER", "DB_PATH", "DB_AUTO_MIGRATE",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+env: dev
+server:
+  port: "9000"
+database:
+  host: file-host
+  user: file-user
+reconciliation:
+  interval: 15m
+rate_limit:
+  write:
+    rps: 2
+    burst: 4
+`)
+
+	cfg, err := loadForTest(t, map[string]string{
+		"DB_HOST":         "env-host",
+		"ADMIN_USERNAMES": "alice, bob",
+	}, "-config", file, "-port", "9100")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Env != EnvDev {
+		t.Errorf("Env = %q, want %q from file", cfg.Env, EnvDev)
+	}
+	if cfg.Database.User != "file-user" {
+		t.Errorf("Database.User = %q, want value from file", cfg.Database.User)
+	}
+	if cfg.Database.Host != "env-host" {
+		t.Errorf("Database.Host = %q, want environment to override file", cfg.Database.Host)
+	}
+	if cfg.Server.Port != "9100" {
+		t.Errorf("Server.Port = %q, want flag to override file", cfg.Server.Port)
+	}
+	if cfg.Database.DBName != "avito_shop" {
+		t.Errorf("Database.DBName = %q, want default", cfg.Database.DBName)
+	}
+	if cfg.Reconciliation.Interval != 15*time.Minute {
+		t.Errorf("Reconciliation.Interval = %v, want %v", cfg.Reconciliation.Interval, 15*time.Minute)
+	}
+	if cfg.RateLimit.Write != (RateLimitRule{RPS: 2, Burst: 4}) {
+		t.Errorf("RateLimit.Write = %+v, want value from file", cfg.RateLimit.Write)
+	}
+	if cfg.RateLimit.Read != defaultConfig().RateLimit.Read {
+		t.Errorf("RateLimit.Read = %+v, want default", cfg.RateLimit.Read)
+	}
+	if len(cfg.Admin.Usernames) != 2 || cfg.Admin.Usernames[1] != "bob" {
+		t.Errorf("Admin.Usernames = %v, want [alice bob]", cfg.Admin.Usernames)
+	}
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	file := writeFile(t, "config.json", `{"env": "dev", "database": {"name": "shop_json"}}`)
+
+	cfg, err := loadForTest(t, nil, "-config", file)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Database.DBName != "shop_json" {
+		t.Errorf("Database.DBName = %q, want %q", cfg.Database.DBName, "shop_json")
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	secret := writeFile(t, "jwt", "s3cr3t-from-file\n")
+	password := writeFile(t, "db", "db-pass\n")
+
+	cfg, err := loadForTest(t, map[string]string{
+		"JWT_SECRET":       "ignored",
+		"DB_PASSWORD_FILE": password,
+	}, "-jwt-secret-file", secret)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.JWT.SecretKey != "s3cr3t-from-file" {
+		t.Errorf("JWT.SecretKey = %q, want contents of the secret file", cfg.JWT.SecretKey)
+	}
+	if cfg.Database.Password != "db-pass" {
+		t.Errorf("Database.Password = %q, want contents of the password file", cfg.Database.Password)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "Default secret outside dev",
+			env:     nil,
+			wantErr: "default JWT secret",
+		},
+		{
+			name: "Default secret in dev",
+			env:  map[string]string{"APP_ENV": "dev"},
+		},
+		{
+			name: "Custom secret in production",
+			env:  map[string]string{"JWT_SECRET": "a-real-secret"},
+		},
+		{
+			name:    "Unknown environment",
+			env:     map[string]string{"APP_ENV": "staging", "JWT_SECRET": "a-real-secret"},
+			wantErr: "env must be",
+		},
+		{
+			name:    "Missing database host",
+			env:     map[string]string{"APP_ENV": "dev", "DB_HOST": ""},
+			wantErr: "database host is required",
+		},
+		{
+			name: "Memory driver needs no database",
+			env:  map[string]string{"APP_ENV": "dev", "DB_DRIVER": "memory", "DB_HOST": ""},
+		},
+		{
+			name:    "SQLite driver needs a path",
+			env:     map[string]string{"APP_ENV": "dev", "DB_DRIVER": "sqlite", "DB_PATH": ""},
+			wantErr: "database path is required",
+		},
+		{
+			name:    "Unknown driver",
+			env:     map[string]string{"APP_ENV": "dev", "DB_DRIVER": "mysql"},
+			wantErr: "database driver must be",
+		},
+		{
+			name:    "Invalid port",
+			env:     map[string]string{"APP_ENV": "dev", "SERVER_PORT": "http"},
+			wantErr: "server port",
+		},
+		{
+			name:    "Zero shutdown timeout",
+			env:     map[string]string{"APP_ENV": "dev", "SERVER_SHUTDOWN_TIMEOUT": "0s"},
+			wantErr: "shutdown timeout",
+		},
+		{
+			name:    "Negative shutdown delay",
+			env:     map[string]string{"APP_ENV": "dev", "SERVER_SHUTDOWN_DELAY": "-1s"},
+			wantErr: "shutdown delay",
+		},
+		{
+			name:    "Unknown log level",
+			env:     map[string]string{"APP_ENV": "dev", "LOG_LEVEL": "verbose"},
+			wantErr: "log level",
+		},
+		{
+			name:    "Unknown log format",
+			env:     map[string]string{"APP_ENV": "dev", "LOG_FORMAT": "xml"},
+			wantErr: "log format",
+		},
+		{
+			name:    "Unknown trace exporter",
+			env:     map[string]string{"APP_ENV": "dev", "TRACING_EXPORTER": "jaeger"},
+			wantErr: "trace exporter",
+		},
+		{
+			name: "OTLP exporter",
+			env:  map[string]string{"APP_ENV": "dev", "TRACING_EXPORTER": "otlp", "TRACING_OTLP_ENDPOINT": "collector:4318"},
+		},
+		{
+			name:    "Malformed interval",
+			env:     map[string]string{"APP_ENV": "dev", "RECONCILIATION_INTERVAL": "hourly"},
+			wantErr: "RECONCILIATION_INTERVAL",
+		},
+		{
+			name:    "Malformed token lifetime",
+			env:     map[string]string{"APP_ENV": "dev", "JWT_ACCESS_TOKEN_TTL": "15"},
+			wantErr: "JWT_ACCESS_TOKEN_TTL",
+		},
+		{
+			name:    "Lockout without delay",
+			env:     map[string]string{"APP_ENV": "dev", "CONFIG_FILE": writeFile(t, "lockout.yaml", "auth: {lockout: {base_delay: 0s}}")},
+			wantErr: "login lockout",
+		},
+		{
+			name:    "Rate limit without burst",
+			env:     map[string]string{"APP_ENV": "dev", "CONFIG_FILE": writeFile(t, "limits.yaml", "rate_limit: {auth: {rps: 1, burst: 0}}")},
+			wantErr: "auth rate limit",
+		},
+		{
+			name:    "Access token outliving refresh token",
+			env:     map[string]string{"APP_ENV": "dev", "JWT_ACCESS_TOKEN_TTL": "48h", "JWT_REFRESH_TOKEN_TTL": "24h"},
+			wantErr: "access token lifetime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadForTest(t, tt.env)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Load() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	// Production with the default JWT secret fails Load but is fine for
+	// commands that only need the database.
+	setEnvForTest(t, map[string]string{"DB_DRIVER": "sqlite", "DB_PATH": "shop.db"})
+	cfg, err := LoadDatabase(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("LoadDatabase() error = %v", err)
+	}
+	if cfg.Driver != DriverSQLite || cfg.Path != "shop.db" {
+		t.Errorf("LoadDatabase() = %+v, want the sqlite driver on shop.db", cfg)
+	}
+
+	setEnvForTest(t, map[string]string{"DB_DRIVER": "sqlite", "DB_PATH": ""})
+	_, err = LoadDatabase(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err == nil || !strings.Contains(err.Error(), "database path") {
+		t.Errorf("LoadDatabase() without a path error = %v, want it to mention the database path", err)
+	}
+}
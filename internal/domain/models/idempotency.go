@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	Key            string    `json:"key"`
+	RequestHash    string    `json:"request_hash"`
+	ResponseStatus *int      `json:"response_status"`
+	ResponseBody   []byte    `json:"response_body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Completed reports whether the response for the key has been stored.
+func (k *IdempotencyKey) Completed() bool {
+	return k.ResponseStatus != nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	AccountTypeUser     = "USER"
+	AccountTypeShop     = "SHOP"
+	AccountTypeIssuance = "ISSUANCE"
+)
+
+// ShopAccountName is shown as the counterparty of purchases.
+const ShopAccountName = "SHOP"
+
+const (
+	EntryTypeGrant          = "GRANT"
+	EntryTypeOpeningBalance = "OPENING_BALANCE"
+	EntryTypeAdjustment     = "ADJUSTMENT"
+	EntryTypeTransfer       = TransactionTypeTransfer
+	EntryTypePurchase       = TransactionTypePurchase
+)
+
+// LedgerAccount holds coins. User accounts belong to a user; the SHOP
+// account collects purchase revenue and the ISSUANCE account is the source of
+// every coin granted, so its balance is the negated money supply.
+type LedgerAccount struct {
+	ID          int64     `json:"id"`
+	AccountType string    `json:"account_type"`
+	UserID      *int64    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// JournalEntry is one business event recorded as postings whose amounts sum
+// to zero.
+type JournalEntry struct {
+	ID            int64     `json:"id"`
+	EntryType     string    `json:"entry_type"`
+	TransactionID *int64    `json:"transaction_id"`
+	Postings      []Posting `json:"postings"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Posting changes the balance of one account; positive amounts credit it.
+type Posting struct {
+	ID        int64 `json:"id"`
+	EntryID   int64 `json:"entry_id"`
+	AccountID int64 `json:"account_id"`
+	Amount    int   `json:"amount"`
+}
+
+type BalanceMismatch struct {
+	UserID          int64  `json:"user_id"`
+	Username        string `json:"username"`
+	StoredBalance   int    `json:"stored_balance"`
+	ExpectedBalance int    `json:"expected_balance"`
+}
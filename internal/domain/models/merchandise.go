@@ -4,4 +4,14 @@ type Merchandise struct {
 	ID    int64  `json:"id"`
 	Name  string `json:"name"`
 	Price int    `json:"price"`
+	// Active is false for retired items, which can no longer be bought but
+	// stay referenced by past purchases.
+	Active bool `json:"active"`
+}
+
+// MerchandiseUpdate lists the fields to change; nil fields are left as is.
+type MerchandiseUpdate struct {
+	Name   *string `json:"name"`
+	Price  *int    `json:"price"`
+	Active *bool   `json:"active"`
 }
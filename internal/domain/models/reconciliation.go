@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ReconciliationReport summarises one run of the balance reconciliation.
+// HistoryMismatches compares users.coins with the starting grant plus the
+// coin_transactions history; LedgerMismatches compares it with the ledger.
+type ReconciliationReport struct {
+	StartedAt         time.Time          `json:"started_at"`
+	FinishedAt        time.Time          `json:"finished_at"`
+	HistoryMismatches []*BalanceMismatch `json:"history_mismatches"`
+	LedgerMismatches  []*BalanceMismatch `json:"ledger_mismatches"`
+	Corrected         int                `json:"corrected"`
+}
+
+// Consistent reports whether no invariant was violated.
+func (r *ReconciliationReport) Consistent() bool {
+	return len(r.HistoryMismatches) == 0 && len(r.LedgerMismatches) == 0
+}
+
+func (m *BalanceMismatch) Difference() int {
+	return m.ExpectedBalance - m.StoredBalance
+}
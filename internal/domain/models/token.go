@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TokenPair is returned on login and refresh. ExpiresIn is the lifetime of
+// the access token in seconds.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// RefreshToken is the server-side record of an opaque refresh token; only a
+// hash of the token itself is stored. Every refresh revokes the presented
+// token and issues a new one in the same family, so reuse of a revoked token
+// reveals that it was stolen and the whole family is revoked.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether the token can still be exchanged at now.
+func (t *RefreshToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// AccessClaims are the verified claims of an access token. TokenID is the
+// jti claim under which the token can be revoked.
+type AccessClaims struct {
+	UserID    int64
+	Role      string
+	TokenID   string
+	FamilyID  string
+	ExpiresAt time.Time
+}
@@ -15,3 +15,44 @@ type Transaction struct {
 	TransactionType string    `json:"transaction_type"`
 	CreatedAt       time.Time `json:"created_at"`
 }
+
+// TransactionDetails is a transaction with the usernames of both parties
+// resolved. ToUsername is empty for purchases.
+type TransactionDetails struct {
+	Transaction
+	FromUsername string `json:"from_username"`
+	ToUsername   string `json:"to_username"`
+}
+
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// TransactionFilter selects a page of a user's transaction history, newest
+// first. Zero values disable the corresponding filter; BeforeID is the
+// keyset cursor and Limit caps the number of rows returned.
+type TransactionFilter struct {
+	UserID       int64
+	Direction    string
+	Type         string
+	Counterparty string
+	From         *time.Time
+	To           *time.Time
+	BeforeID     int64
+	Limit        int
+}
+
+type TransactionHistoryItem struct {
+	ID           int64     `json:"id"`
+	Type         string    `json:"type"`
+	Direction    string    `json:"direction"`
+	Counterparty string    `json:"counterparty"`
+	Amount       int       `json:"amount"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type TransactionPage struct {
+	Transactions []TransactionHistoryItem `json:"transactions"`
+	NextCursor   string                   `json:"nextCursor,omitempty"`
+}
@@ -2,10 +2,16 @@ package models
 
 import "time"
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID           int64     `json:"id"`
 	Username     string    `json:"username"`
 	PasswordHash string    `json:"-"`
 	Coins        int       `json:"coins"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 }
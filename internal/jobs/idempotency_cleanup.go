@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"avito-shop/internal/service"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// IdempotencyCleanupJob periodically deletes idempotency keys that have
+// outlived their TTL, so that the table does not grow without bound.
+type IdempotencyCleanupJob struct {
+	service  service.IdempotencyService
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewIdempotencyCleanupJob(service service.IdempotencyService, interval time.Duration, logger *slog.Logger) *IdempotencyCleanupJob {
+	return &IdempotencyCleanupJob{
+		service:  service,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run blocks, cleaning up once per interval until ctx is cancelled.
+func (j *IdempotencyCleanupJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.service.DeleteExpiredKeys(ctx); err != nil {
+				j.logger.ErrorContext(ctx, "Idempotency key cleanup failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/service"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ReconciliationJob periodically checks that stored balances agree with the
+// transaction history and the ledger.
+type ReconciliationJob struct {
+	service  service.ReconciliationService
+	interval time.Duration
+	autoFix  bool
+	logger   *slog.Logger
+}
+
+func NewReconciliationJob(service service.ReconciliationService, interval time.Duration, autoFix bool, logger *slog.Logger) *ReconciliationJob {
+	return &ReconciliationJob{
+		service:  service,
+		interval: interval,
+		autoFix:  autoFix,
+		logger:   logger,
+	}
+}
+
+// Run blocks, reconciling once per interval until ctx is cancelled.
+func (j *ReconciliationJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := j.service.Reconcile(ctx, j.autoFix)
+			if err != nil {
+				j.logger.ErrorContext(ctx, "Balance reconciliation failed", "error", err)
+				continue
+			}
+			LogReport(j.logger, report)
+		}
+	}
+}
+
+// LogReport logs every mismatch in report and a summary.
+func LogReport(logger *slog.Logger, report *models.ReconciliationReport) {
+	duration := report.FinishedAt.Sub(report.StartedAt)
+	if report.Consistent() {
+		logger.Info("Balance reconciliation finished: no mismatches", "duration", duration)
+		return
+	}
+
+	for _, m := range report.HistoryMismatches {
+		logger.Warn("Balance mismatch with history", "user_id", m.UserID, "username", m.Username,
+			"stored", m.StoredBalance, "expected", m.ExpectedBalance)
+	}
+	for _, m := range report.LedgerMismatches {
+		logger.Warn("Balance mismatch with ledger", "user_id", m.UserID, "username", m.Username,
+			"stored", m.StoredBalance, "expected", m.ExpectedBalance)
+	}
+	logger.Warn("Balance reconciliation finished with mismatches", "duration", duration,
+		"history_mismatches", len(report.HistoryMismatches), "ledger_mismatches", len(report.LedgerMismatches),
+		"corrected", report.Corrected)
+}
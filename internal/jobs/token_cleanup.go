@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"avito-shop/internal/service"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// TokenCleanupJob periodically deletes expired refresh tokens, access token
+// revocations and failed login counters, which no longer affect
+// authentication.
+type TokenCleanupJob struct {
+	service  service.AuthService
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewTokenCleanupJob(service service.AuthService, interval time.Duration, logger *slog.Logger) *TokenCleanupJob {
+	return &TokenCleanupJob{
+		service:  service,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run blocks, cleaning up once per interval until ctx is cancelled.
+func (j *TokenCleanupJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.service.DeleteExpiredTokens(ctx); err != nil {
+				j.logger.ErrorContext(ctx, "Expired token cleanup failed", "error", err)
+			}
+			if err := j.service.DeleteStaleLoginAttempts(ctx); err != nil {
+				j.logger.ErrorContext(ctx, "Login attempt cleanup failed", "error", err)
+			}
+		}
+	}
+}
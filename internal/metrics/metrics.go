@@ -0,0 +1,91 @@
+// Package metrics defines the Prometheus collectors of the service. They are
+// registered on Registry rather than on the global default registry, so
+// /metrics exposes exactly what is listed here plus the Go runtime and
+// process statistics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "avito_shop"
+
+// Coin operations counted by CoinsMoved.
+const (
+	OperationTransfer = "transfer"
+	OperationPurchase = "purchase"
+)
+
+// latencyBuckets are finer than the Prometheus defaults below 50ms, which is
+// where the load test thresholds lie.
+var latencyBuckets = []float64{.001, .0025, .005, .01, .02, .03, .05, .1, .25, .5, 1, 2.5}
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	// HTTPRequestDuration is labelled by route pattern rather than path, so
+	// that item names and other path parameters do not create new series.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   latencyBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database calls by repository and method.",
+		Buckets:   latencyBuckets,
+	}, []string{"repository", "method"})
+
+	CoinOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coin_operations_total",
+		Help:      "Completed coin transfers and purchases.",
+	}, []string{"operation"})
+
+	CoinsMoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coins_moved_total",
+		Help:      "Coins moved by completed transfers and purchases.",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		DBQueryDuration,
+		CoinOperations,
+		CoinsMoved,
+	)
+}
+
+// Handler serves the metrics in Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDBQuery starts timing a call to method of repository. The returned
+// function records the elapsed time, so the usual form is
+//
+//	defer metrics.ObserveDBQuery("users", "GetByID")()
+func ObserveDBQuery(repository, method string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RecordCoins counts a completed operation that moved amount coins.
+func RecordCoins(operation string, amount int) {
+	CoinOperations.WithLabelValues(operation).Inc()
+	CoinsMoved.WithLabelValues(operation).Add(float64(amount))
+}
@@ -0,0 +1,171 @@
+package migrate_test
+
+import (
+	"avito-shop/internal/migrate"
+	"avito-shop/internal/test"
+	"avito-shop/migrations"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		wantErr  bool
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"010_later.up.sql":    {Data: []byte("SELECT 10;")},
+				"002_second.up.sql":   {Data: []byte("SELECT 2;")},
+				"002_second.down.sql": {Data: []byte("SELECT -2;")},
+				"001_first.up.sql":    {Data: []byte("SELECT 1;")},
+				"README.md":           {Data: []byte("ignored")},
+				"embed.go":            {Data: []byte("package migrations")},
+			},
+			versions: []int{1, 2, 10},
+		},
+		{
+			name: "missing up file",
+			files: fstest.MapFS{
+				"001_first.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "conflicting names",
+			files: fstest.MapFS{
+				"001_first.up.sql":   {Data: []byte("SELECT 1;")},
+				"001_other.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := migrate.Load(tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(loaded) != len(tt.versions) {
+				t.Fatalf("Load() returned %d migrations, want %d", len(loaded), len(tt.versions))
+			}
+			for i, m := range loaded {
+				if m.Version != tt.versions[i] {
+					t.Errorf("migration %d has version %d, want %d", i, m.Version, tt.versions[i])
+				}
+				if m.Checksum == "" {
+					t.Errorf("migration %d has no checksum", m.Version)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, cleanup := test.SetupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	migrator, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatalf("Failed to create migrator: %v", err)
+	}
+
+	// SetupTestDB has already applied everything.
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Up() applied %v on an up-to-date database", applied)
+	}
+
+	t.Run("down and up again", func(t *testing.T) {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+
+		reverted, err := migrator.Down(ctx, len(statuses))
+		if err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+		if len(reverted) != len(statuses) {
+			t.Errorf("Down() reverted %d migrations, want %d", len(reverted), len(statuses))
+		}
+		if err := migrator.Check(ctx); !errors.Is(err, migrate.ErrPending) {
+			t.Errorf("Check() error = %v, want %v", err, migrate.ErrPending)
+		}
+
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			t.Fatalf("Up() error = %v", err)
+		}
+		if len(applied) != len(statuses) {
+			t.Errorf("Up() applied %d migrations, want %d", len(applied), len(statuses))
+		}
+	})
+
+	t.Run("no version table", func(t *testing.T) {
+		if _, err := db.Exec(`ALTER TABLE schema_migrations RENAME TO schema_migrations_saved`); err != nil {
+			t.Fatalf("Failed to hide the version table: %v", err)
+		}
+		t.Cleanup(func() {
+			if _, err := db.Exec(`ALTER TABLE schema_migrations_saved RENAME TO schema_migrations`); err != nil {
+				t.Fatalf("Failed to restore the version table: %v", err)
+			}
+		})
+
+		if err := migrator.Check(ctx); !errors.Is(err, migrate.ErrPending) {
+			t.Errorf("Check() error = %v, want %v", err, migrate.ErrPending)
+		}
+
+		var table sql.NullString
+		if err := db.QueryRow(`SELECT to_regclass('schema_migrations')::text`).Scan(&table); err != nil {
+			t.Fatalf("Failed to look up the version table: %v", err)
+		}
+		if table.Valid {
+			t.Error("Check() created the version table")
+		}
+	})
+
+	t.Run("modified migration", func(t *testing.T) {
+		if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'tampered' WHERE version = 1`); err != nil {
+			t.Fatalf("Failed to tamper with checksum: %v", err)
+		}
+
+		if _, err := migrator.Up(ctx); !errors.Is(err, migrate.ErrChecksumMismatch) {
+			t.Errorf("Up() error = %v, want %v", err, migrate.ErrChecksumMismatch)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Package memory implements the repository interfaces on plain Go maps. It
+// mirrors the semantics of the postgres package, including uniqueness
+// constraints and transactions, and is meant for tests and local development.
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"sync"
+	"time"
+)
+
+// DB holds the tables shared by the repositories. Transactions are
+// serialised: WithinTx holds the lock for its whole duration and works on a
+// copy of the data that replaces the original only on commit.
+type DB struct {
+	mu    sync.Mutex
+	state *state
+}
+
+// NewDB returns an empty database with the system ledger accounts seeded, as
+// the migrations do for Postgres.
+func NewDB() *DB {
+	s := &state{
+		sequences:       map[string]int64{},
+		users:           map[int64]models.User{},
+		merchandise:     map[int64]models.Merchandise{},
+		idempotencyKeys: map[int64]models.IdempotencyKey{},
+		accounts:        map[int64]models.LedgerAccount{},
+		refreshTokens:   map[int64]models.RefreshToken{},
+		revokedTokens:   map[string]time.Time{},
+		loginAttempts:   map[loginAttemptKey]models.LoginAttempt{},
+	}
+	for _, accountType := range []string{models.AccountTypeShop, models.AccountTypeIssuance} {
+		id := s.nextID("ledger_accounts")
+		s.accounts[id] = models.LedgerAccount{ID: id, AccountType: accountType, CreatedAt: now()}
+	}
+
+	return &DB{state: s}
+}
+
+// conn gives a repository access to the data, either through the shared
+// database or through the copy owned by a transaction.
+type conn interface {
+	acquire() (*state, func())
+}
+
+func (db *DB) acquire() (*state, func()) {
+	db.mu.Lock()
+	return db.state, db.mu.Unlock
+}
+
+type txConn struct {
+	state *state
+}
+
+func (c txConn) acquire() (*state, func()) {
+	return c.state, func() {}
+}
+
+type inventoryRow struct {
+	id            int64
+	userID        int64
+	merchandiseID int64
+}
+
+type state struct {
+	sequences       map[string]int64
+	users           map[int64]models.User
+	merchandise     map[int64]models.Merchandise
+	inventory       []inventoryRow
+	transactions    []models.Transaction
+	idempotencyKeys map[int64]models.IdempotencyKey
+	accounts        map[int64]models.LedgerAccount
+	entries         []models.JournalEntry
+	postings        []models.Posting
+	refreshTokens   map[int64]models.RefreshToken
+	revokedTokens   map[string]time.Time
+	loginAttempts   map[loginAttemptKey]models.LoginAttempt
+}
+
+// nextID works like a SERIAL column.
+func (s *state) nextID(table string) int64 {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// clone copies every table. Stored rows are never modified in place, so a
+// shallow copy of each row is enough.
+func (s *state) clone() *state {
+	return &state{
+		sequences:       cloneMap(s.sequences),
+		users:           cloneMap(s.users),
+		merchandise:     cloneMap(s.merchandise),
+		inventory:       append([]inventoryRow(nil), s.inventory...),
+		transactions:    append([]models.Transaction(nil), s.transactions...),
+		idempotencyKeys: cloneMap(s.idempotencyKeys),
+		accounts:        cloneMap(s.accounts),
+		entries:         append([]models.JournalEntry(nil), s.entries...),
+		postings:        append([]models.Posting(nil), s.postings...),
+		refreshTokens:   cloneMap(s.refreshTokens),
+		revokedTokens:   cloneMap(s.revokedTokens),
+		loginAttempts:   cloneMap(s.loginAttempts),
+	}
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func NewRepositories(db *DB) *repository.Repositories {
+	return newRepositories(db)
+}
+
+func newRepositories(db conn) *repository.Repositories {
+	return &repository.Repositories{
+		Users:         &UserRepository{db: db},
+		Merchandise:   &MerchandiseRepository{db: db},
+		Transactions:  &TransactionRepository{db: db},
+		Inventory:     &UserInventoryRepository{db: db},
+		Idempotency:   &IdempotencyRepository{db: db},
+		Ledger:        &LedgerRepository{db: db},
+		Tokens:        &TokenRepository{db: db},
+		LoginAttempts: &LoginAttemptRepository{db: db},
+	}
+}
+
+type TxManager struct {
+	db *DB
+}
+
+func NewTxManager(db *DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// WithinTx runs fn against repositories bound to a private copy of the data.
+// The copy replaces the database only if fn returns nil and ctx is still
+// live. Repositories created with NewRepositories must not be used inside fn:
+// they would wait for the lock the transaction holds.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.db.mu.Lock()
+	defer m.db.mu.Unlock()
+
+	tx := m.db.state.clone()
+	if err := fn(newRepositories(txConn{state: tx})); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.db.state = tx
+	return nil
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func copyTime(v *time.Time) *time.Time {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+func copyInt64(v *int64) *int64 {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
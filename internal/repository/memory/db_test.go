@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) (*repository.Repositories, repository.TxManager) {
+		db := NewDB()
+		return NewRepositories(db), NewTxManager(db)
+	})
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db conn
+}
+
+func NewIdempotencyRepository(db *DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.idempotencyKey(key.UserID, key.Key); ok {
+		return false, nil
+	}
+
+	key.ID = s.nextID("idempotency_keys")
+	key.CreatedAt = now()
+	s.idempotencyKeys[key.ID] = models.IdempotencyKey{
+		ID:          key.ID,
+		UserID:      key.UserID,
+		Key:         key.Key,
+		RequestHash: key.RequestHash,
+		CreatedAt:   key.CreatedAt,
+	}
+
+	return true, nil
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, userID int64, key string) (*models.IdempotencyKey, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	record, ok := s.idempotencyKey(userID, key)
+	if !ok {
+		return nil, nil
+	}
+
+	if record.ResponseStatus != nil {
+		status := *record.ResponseStatus
+		record.ResponseStatus = &status
+	}
+	record.ResponseBody = append([]byte(nil), record.ResponseBody...)
+
+	return &record, nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, id int64, status int, body []byte) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	record, ok := s.idempotencyKeys[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	record.ResponseStatus = &status
+	record.ResponseBody = append([]byte(nil), body...)
+	s.idempotencyKeys[id] = record
+
+	return nil
+}
+
+func (r *IdempotencyRepository) Delete(ctx context.Context, id int64) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	delete(s.idempotencyKeys, id)
+
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	for id, record := range s.idempotencyKeys {
+		if record.CreatedAt.Before(before) {
+			delete(s.idempotencyKeys, id)
+		}
+	}
+	return nil
+}
+
+func (s *state) idempotencyKey(userID int64, key string) (models.IdempotencyKey, bool) {
+	for _, record := range s.idempotencyKeys {
+		if record.UserID == userID && record.Key == key {
+			return record, true
+		}
+	}
+	return models.IdempotencyKey{}, false
+}
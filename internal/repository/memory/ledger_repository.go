@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"fmt"
+)
+
+type LedgerRepository struct {
+	db conn
+}
+
+func NewLedgerRepository(db *DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+func (r *LedgerRepository) CreateAccount(ctx context.Context, account *models.LedgerAccount) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if (account.AccountType == models.AccountTypeUser) != (account.UserID != nil) {
+		return fmt.Errorf("only %s accounts belong to a user", models.AccountTypeUser)
+	}
+	if account.UserID != nil {
+		if _, ok := s.users[*account.UserID]; !ok {
+			return repository.ErrReferenced
+		}
+	}
+
+	for _, existing := range s.accounts {
+		if account.UserID != nil && existing.UserID != nil && *existing.UserID == *account.UserID {
+			return repository.ErrDuplicate
+		}
+		if account.UserID == nil && existing.UserID == nil && existing.AccountType == account.AccountType {
+			return repository.ErrDuplicate
+		}
+	}
+
+	account.ID = s.nextID("ledger_accounts")
+	account.CreatedAt = now()
+
+	stored := *account
+	stored.UserID = copyInt64(account.UserID)
+	s.accounts[account.ID] = stored
+
+	return nil
+}
+
+func (r *LedgerRepository) GetUserAccount(ctx context.Context, userID int64) (*models.LedgerAccount, error) {
+	return r.getAccount(func(a models.LedgerAccount) bool {
+		return a.UserID != nil && *a.UserID == userID
+	})
+}
+
+func (r *LedgerRepository) GetSystemAccount(ctx context.Context, accountType string) (*models.LedgerAccount, error) {
+	return r.getAccount(func(a models.LedgerAccount) bool {
+		return a.UserID == nil && a.AccountType == accountType
+	})
+}
+
+func (r *LedgerRepository) getAccount(match func(models.LedgerAccount) bool) (*models.LedgerAccount, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	for _, account := range s.accounts {
+		if match(account) {
+			account.UserID = copyInt64(account.UserID)
+			return &account, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateEntry enforces the same invariants as the ledger triggers: postings
+// are non-zero, reference existing accounts and sum to zero.
+func (r *LedgerRepository) CreateEntry(ctx context.Context, entry *models.JournalEntry) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if entry.TransactionID != nil && !s.hasTransaction(*entry.TransactionID) {
+		return repository.ErrReferenced
+	}
+
+	sum := 0
+	for _, posting := range entry.Postings {
+		if posting.Amount == 0 {
+			return fmt.Errorf("ledger posting amount must not be zero")
+		}
+		if _, ok := s.accounts[posting.AccountID]; !ok {
+			return repository.ErrReferenced
+		}
+		sum += posting.Amount
+	}
+
+	entry.ID = s.nextID("ledger_entries")
+	entry.CreatedAt = now()
+	if sum != 0 {
+		return fmt.Errorf("ledger entry %d is not balanced", entry.ID)
+	}
+
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		posting.EntryID = entry.ID
+		posting.ID = s.nextID("ledger_postings")
+		s.postings = append(s.postings, *posting)
+	}
+
+	stored := *entry
+	stored.TransactionID = copyInt64(entry.TransactionID)
+	stored.Postings = nil
+	s.entries = append(s.entries, stored)
+
+	return nil
+}
+
+func (r *LedgerRepository) GetBalance(ctx context.Context, accountID int64) (int, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	return s.balance(accountID), nil
+}
+
+func (r *LedgerRepository) GetBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	balances := map[int64]int{}
+	for _, account := range s.accounts {
+		if account.UserID != nil {
+			balances[*account.UserID] = s.balance(account.ID)
+		}
+	}
+
+	var mismatches []*models.BalanceMismatch
+	for _, user := range s.usersByID() {
+		if balance := balances[user.ID]; user.Coins != balance {
+			mismatches = append(mismatches, &models.BalanceMismatch{
+				UserID:          user.ID,
+				Username:        user.Username,
+				StoredBalance:   user.Coins,
+				ExpectedBalance: balance,
+			})
+		}
+	}
+
+	return mismatches, nil
+}
+
+func (s *state) balance(accountID int64) int {
+	balance := 0
+	for _, posting := range s.postings {
+		if posting.AccountID == accountID {
+			balance += posting.Amount
+		}
+	}
+	return balance
+}
+
+func (s *state) hasTransaction(id int64) bool {
+	for _, t := range s.transactions {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"context"
+	"time"
+)
+
+type loginAttemptKey struct {
+	scope string
+	key   string
+}
+
+type LoginAttemptRepository struct {
+	db conn
+}
+
+func NewLoginAttemptRepository(db *DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, scope, key string) (*models.LoginAttempt, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	attempt, ok := s.loginAttempts[loginAttemptKey{scope, key}]
+	if !ok {
+		return nil, nil
+	}
+	attempt.LockedUntil = copyTime(attempt.LockedUntil)
+	return &attempt, nil
+}
+
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, scope, key string, at, windowStart time.Time) (int, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	k := loginAttemptKey{scope, key}
+	attempt, ok := s.loginAttempts[k]
+	if !ok {
+		attempt = models.LoginAttempt{Scope: scope, Key: key}
+	}
+	if attempt.LastFailureAt.Before(windowStart.UTC()) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at.UTC()
+	s.loginAttempts[k] = attempt
+
+	return attempt.Failures, nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	k := loginAttemptKey{scope, key}
+	attempt, ok := s.loginAttempts[k]
+	if !ok {
+		return nil
+	}
+	until = until.UTC()
+	attempt.LockedUntil = &until
+	s.loginAttempts[k] = attempt
+
+	return nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, scope, key string) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	delete(s.loginAttempts, loginAttemptKey{scope, key})
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	for k, attempt := range s.loginAttempts {
+		if attempt.LastFailureAt.Before(before) && (attempt.LockedUntil == nil || attempt.LockedUntil.Before(before)) {
+			delete(s.loginAttempts, k)
+		}
+	}
+	return nil
+}
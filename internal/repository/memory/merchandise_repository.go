@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+	"sort"
+)
+
+type MerchandiseRepository struct {
+	db conn
+}
+
+func NewMerchandiseRepository(db *DB) *MerchandiseRepository {
+	return &MerchandiseRepository{db: db}
+}
+
+func (r *MerchandiseRepository) GetByName(ctx context.Context, name string) (*models.Merchandise, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	for _, item := range s.merchandise {
+		if item.Name == name {
+			return &item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *MerchandiseRepository) GetAll(ctx context.Context) ([]*models.Merchandise, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	var items []*models.Merchandise
+	for _, item := range s.merchandise {
+		item := item
+		items = append(items, &item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	return items, nil
+}
+
+func (r *MerchandiseRepository) Create(ctx context.Context, item *models.Merchandise) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if s.merchandiseNameTaken(item.Name, 0) {
+		return repository.ErrDuplicate
+	}
+
+	item.ID = s.nextID("merchandise")
+	s.merchandise[item.ID] = *item
+
+	return nil
+}
+
+func (r *MerchandiseRepository) Update(ctx context.Context, item *models.Merchandise) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.merchandise[item.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	if s.merchandiseNameTaken(item.Name, item.ID) {
+		return repository.ErrDuplicate
+	}
+
+	s.merchandise[item.ID] = *item
+
+	return nil
+}
+
+func (r *MerchandiseRepository) Delete(ctx context.Context, id int64) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.merchandise[id]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, row := range s.inventory {
+		if row.merchandiseID == id {
+			return repository.ErrReferenced
+		}
+	}
+
+	delete(s.merchandise, id)
+
+	return nil
+}
+
+// merchandiseNameTaken reports whether an item other than exceptID already
+// uses name.
+func (s *state) merchandiseNameTaken(name string, exceptID int64) bool {
+	for _, item := range s.merchandise {
+		if item.Name == name && item.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
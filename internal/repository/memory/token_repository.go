@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type TokenRepository struct {
+	db conn
+}
+
+func NewTokenRepository(db *DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.users[token.UserID]; !ok {
+		return repository.ErrReferenced
+	}
+	if _, ok := s.refreshToken(token.TokenHash); ok {
+		return repository.ErrDuplicate
+	}
+
+	token.ID = s.nextID("refresh_tokens")
+	token.CreatedAt = now()
+
+	stored := *token
+	stored.ExpiresAt = token.ExpiresAt.UTC()
+	stored.RevokedAt = nil
+	s.refreshTokens[token.ID] = stored
+
+	return nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	token, ok := s.refreshToken(tokenHash)
+	if !ok {
+		return nil, nil
+	}
+	token.RevokedAt = copyTime(token.RevokedAt)
+	return &token, nil
+}
+
+func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	token, ok := s.refreshTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	revokedAt := now()
+	token.RevokedAt = &revokedAt
+	s.refreshTokens[id] = token
+
+	return nil
+}
+
+func (r *TokenRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	revokedAt := now()
+	for id, token := range s.refreshTokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+			s.refreshTokens[id] = token
+		}
+	}
+
+	return nil
+}
+
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.revokedTokens[tokenID]; !ok {
+		s.revokedTokens[tokenID] = expiresAt.UTC()
+	}
+
+	return nil
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	_, revoked := s.revokedTokens[tokenID]
+	return revoked, nil
+}
+
+func (r *TokenRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	for id, token := range s.refreshTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(s.refreshTokens, id)
+		}
+	}
+	for tokenID, expiresAt := range s.revokedTokens {
+		if expiresAt.Before(before) {
+			delete(s.revokedTokens, tokenID)
+		}
+	}
+
+	return nil
+}
+
+func (s *state) refreshToken(tokenHash string) (models.RefreshToken, bool) {
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return token, true
+		}
+	}
+	return models.RefreshToken{}, false
+}
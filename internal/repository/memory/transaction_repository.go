@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"sort"
+)
+
+type TransactionRepository struct {
+	db conn
+}
+
+func NewTransactionRepository(db *DB) *TransactionRepository {
+	return &TransactionRepository{db: db}
+}
+
+func (r *TransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.users[transaction.FromUserID]; !ok {
+		return repository.ErrReferenced
+	}
+	if transaction.ToUserID != nil {
+		if _, ok := s.users[*transaction.ToUserID]; !ok {
+			return repository.ErrReferenced
+		}
+	}
+
+	transaction.ID = s.nextID("coin_transactions")
+	transaction.CreatedAt = now()
+
+	stored := *transaction
+	stored.ToUserID = copyInt64(transaction.ToUserID)
+	s.transactions = append(s.transactions, stored)
+
+	return nil
+}
+
+func (r *TransactionRepository) GetUserTransactions(ctx context.Context, userID int64) ([]*models.Transaction, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	var transactions []*models.Transaction
+	for _, t := range s.newestFirst() {
+		if involves(t, userID) {
+			t.ToUserID = copyInt64(t.ToUserID)
+			transactions = append(transactions, &t)
+		}
+	}
+
+	return transactions, nil
+}
+
+func (r *TransactionRepository) GetUserHistory(ctx context.Context, userID int64) ([]*models.TransactionDetails, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	var history []*models.TransactionDetails
+	for _, t := range s.newestFirst() {
+		if involves(t, userID) {
+			history = append(history, s.details(t))
+		}
+	}
+
+	return history, nil
+}
+
+func (r *TransactionRepository) ListUserHistory(ctx context.Context, filter models.TransactionFilter) ([]*models.TransactionDetails, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	var history []*models.TransactionDetails
+	for _, t := range s.newestFirst() {
+		if len(history) >= filter.Limit {
+			break
+		}
+		if d := s.details(t); matches(d, filter) {
+			history = append(history, d)
+		}
+	}
+
+	return history, nil
+}
+
+func matches(d *models.TransactionDetails, filter models.TransactionFilter) bool {
+	sent := d.FromUserID == filter.UserID
+	received := d.ToUserID != nil && *d.ToUserID == filter.UserID
+
+	switch filter.Direction {
+	case models.DirectionSent:
+		if !sent {
+			return false
+		}
+	case models.DirectionReceived:
+		if !received {
+			return false
+		}
+	default:
+		if !sent && !received {
+			return false
+		}
+	}
+
+	if filter.Type != "" && d.TransactionType != filter.Type {
+		return false
+	}
+	switch filter.Counterparty {
+	case "":
+	case models.ShopAccountName:
+		// Purchases are attributed to the shop, which is told apart by the
+		// transaction type rather than by name: a user may be called the same.
+		if !sent || d.TransactionType != models.TransactionTypePurchase {
+			return false
+		}
+	default:
+		if !(sent && d.ToUserID != nil && d.ToUsername == filter.Counterparty) && !(received && d.FromUsername == filter.Counterparty) {
+			return false
+		}
+	}
+	if filter.From != nil && d.CreatedAt.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !d.CreatedAt.Before(*filter.To) {
+		return false
+	}
+	if filter.BeforeID > 0 && d.ID >= filter.BeforeID {
+		return false
+	}
+
+	return true
+}
+
+func (r *TransactionRepository) GetHistoryMismatches(ctx context.Context, initialBalance int) ([]*models.BalanceMismatch, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	delta := map[int64]int{}
+	for _, t := range s.transactions {
+		delta[t.FromUserID] -= t.Amount
+		if t.ToUserID != nil {
+			delta[*t.ToUserID] += t.Amount
+		}
+	}
+
+	var mismatches []*models.BalanceMismatch
+	for _, user := range s.usersByID() {
+		if expected := initialBalance + delta[user.ID]; user.Coins != expected {
+			mismatches = append(mismatches, &models.BalanceMismatch{
+				UserID:          user.ID,
+				Username:        user.Username,
+				StoredBalance:   user.Coins,
+				ExpectedBalance: expected,
+			})
+		}
+	}
+
+	return mismatches, nil
+}
+
+func involves(t models.Transaction, userID int64) bool {
+	return t.FromUserID == userID || (t.ToUserID != nil && *t.ToUserID == userID)
+}
+
+// newestFirst returns the transactions ordered by id, newest first.
+func (s *state) newestFirst() []models.Transaction {
+	transactions := make([]models.Transaction, len(s.transactions))
+	for i, t := range s.transactions {
+		transactions[len(transactions)-1-i] = t
+	}
+	return transactions
+}
+
+func (s *state) details(t models.Transaction) *models.TransactionDetails {
+	d := &models.TransactionDetails{Transaction: t}
+	d.ToUserID = copyInt64(t.ToUserID)
+	d.FromUsername = s.users[t.FromUserID].Username
+	if t.ToUserID != nil {
+		d.ToUsername = s.users[*t.ToUserID].Username
+	}
+	return d
+}
+
+func (s *state) usersByID() []models.User {
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
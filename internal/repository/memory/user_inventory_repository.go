@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"sort"
+)
+
+type UserInventoryRepository struct {
+	db conn
+}
+
+func NewUserInventoryRepository(db *DB) *UserInventoryRepository {
+	return &UserInventoryRepository{db: db}
+}
+
+func (r *UserInventoryRepository) AddItem(ctx context.Context, userID int64, merchandiseID int64) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.users[userID]; !ok {
+		return repository.ErrReferenced
+	}
+	if _, ok := s.merchandise[merchandiseID]; !ok {
+		return repository.ErrReferenced
+	}
+
+	s.inventory = append(s.inventory, inventoryRow{
+		id:            s.nextID("user_inventory"),
+		userID:        userID,
+		merchandiseID: merchandiseID,
+	})
+
+	return nil
+}
+
+func (r *UserInventoryRepository) GetUserItems(ctx context.Context, userID int64) ([]*models.InventoryItem, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	counts := map[string]int{}
+	for _, row := range s.inventory {
+		if row.userID == userID {
+			counts[s.merchandise[row.merchandiseID].Name]++
+		}
+	}
+
+	var items []*models.InventoryItem
+	for name, quantity := range counts {
+		items = append(items, &models.InventoryItem{Type: name, Quantity: quantity})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Type < items[j].Type
+	})
+
+	return items, nil
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+	"sort"
+)
+
+type UserRepository struct {
+	db conn
+}
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	if _, ok := s.userByName(user.Username); ok {
+		return repository.ErrDuplicate
+	}
+
+	user.ID = s.nextID("users")
+	user.CreatedAt = now()
+	s.users[user.ID] = *user
+
+	return nil
+}
+
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	user, ok := s.userByName(username)
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateCoins(ctx context.Context, userID int64, amount int) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	user.Coins += amount
+	s.users[userID] = user
+
+	return nil
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, userID int64, role string) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	user.Role = role
+	s.users[userID] = user
+
+	return nil
+}
+
+func (r *UserRepository) ListByRole(ctx context.Context, role string) ([]*models.User, error) {
+	s, release := r.db.acquire()
+	defer release()
+
+	var users []*models.User
+	for _, user := range s.users {
+		if user.Role == role {
+			user := user
+			users = append(users, &user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
+func (r *UserRepository) Debit(ctx context.Context, userID int64, amount int) error {
+	s, release := r.db.acquire()
+	defer release()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	if user.Coins < amount {
+		return &repository.InsufficientFundsError{
+			UserID:  userID,
+			Balance: user.Coins,
+			Amount:  amount,
+		}
+	}
+
+	user.Coins -= amount
+	s.users[userID] = user
+
+	return nil
+}
+
+// LockForUpdate is a no-op: transactions hold the lock on the whole store.
+func (r *UserRepository) LockForUpdate(ctx context.Context, userIDs ...int64) error {
+	return nil
+}
+
+func (s *state) userByName(username string) (models.User, bool) {
+	for _, user := range s.users {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return models.User{}, false
+}
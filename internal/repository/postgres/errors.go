@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"avito-shop/internal/repository"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// translateError maps constraint violations to the driver-independent errors
+// of the repository package.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case uniqueViolation:
+		return repository.ErrDuplicate
+	case foreignKeyViolation:
+		return repository.ErrReferenced
+	}
+	return err
+}
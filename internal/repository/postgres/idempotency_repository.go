@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db tracedDB
+}
+
+func NewIdempotencyRepository(db DBTX) *IdempotencyRepository {
+	return &IdempotencyRepository{db: tracedDB{db}}
+}
+
+func (r *IdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	defer metrics.ObserveDBQuery("idempotency", "Reserve")()
+
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, idempotency_key) DO NOTHING
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		key.UserID,
+		key.Key,
+		key.RequestHash,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *IdempotencyRepository) Get(ctx context.Context, userID int64, key string) (*models.IdempotencyKey, error) {
+	defer metrics.ObserveDBQuery("idempotency", "Get")()
+
+	record := &models.IdempotencyKey{}
+	query := `
+		SELECT id, user_id, idempotency_key, request_hash, response_status, response_body, created_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND idempotency_key = $2`
+
+	var status sql.NullInt64
+	err := r.db.QueryRowContext(ctx, query, userID, key).Scan(
+		&record.ID,
+		&record.UserID,
+		&record.Key,
+		&record.RequestHash,
+		&status,
+		&record.ResponseBody,
+		&record.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if status.Valid {
+		code := int(status.Int64)
+		record.ResponseStatus = &code
+	}
+
+	return record, nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, id int64, status int, body []byte) error {
+	defer metrics.ObserveDBQuery("idempotency", "SaveResponse")()
+
+	query := `
+		UPDATE idempotency_keys
+		SET response_status = $1, response_body = $2
+		WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, status, body, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepository) Delete(ctx context.Context, id int64) error {
+	defer metrics.ObserveDBQuery("idempotency", "Delete")()
+
+	query := `DELETE FROM idempotency_keys WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	defer metrics.ObserveDBQuery("idempotency", "DeleteExpired")()
+
+	query := `DELETE FROM idempotency_keys WHERE created_at < $1`
+
+	_, err := r.db.ExecContext(ctx, query, before.UTC())
+	return err
+}
@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
+	"context"
+	"database/sql"
+)
+
+type LedgerRepository struct {
+	db tracedDB
+}
+
+func NewLedgerRepository(db DBTX) *LedgerRepository {
+	return &LedgerRepository{db: tracedDB{db}}
+}
+
+func (r *LedgerRepository) CreateAccount(ctx context.Context, account *models.LedgerAccount) error {
+	defer metrics.ObserveDBQuery("ledger", "CreateAccount")()
+
+	query := `
+		INSERT INTO ledger_accounts (account_type, user_id)
+		VALUES ($1, $2)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		account.AccountType,
+		account.UserID,
+	).Scan(&account.ID, &account.CreatedAt)
+	return translateError(err)
+}
+
+func (r *LedgerRepository) GetUserAccount(ctx context.Context, userID int64) (*models.LedgerAccount, error) {
+	defer metrics.ObserveDBQuery("ledger", "GetUserAccount")()
+
+	query := `
+		SELECT id, account_type, user_id, created_at
+		FROM ledger_accounts
+		WHERE user_id = $1`
+
+	return r.getAccount(ctx, query, userID)
+}
+
+func (r *LedgerRepository) GetSystemAccount(ctx context.Context, accountType string) (*models.LedgerAccount, error) {
+	defer metrics.ObserveDBQuery("ledger", "GetSystemAccount")()
+
+	query := `
+		SELECT id, account_type, user_id, created_at
+		FROM ledger_accounts
+		WHERE account_type = $1 AND user_id IS NULL`
+
+	return r.getAccount(ctx, query, accountType)
+}
+
+func (r *LedgerRepository) getAccount(ctx context.Context, query string, arg interface{}) (*models.LedgerAccount, error) {
+	account := &models.LedgerAccount{}
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+		&account.ID,
+		&account.AccountType,
+		&account.UserID,
+		&account.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (r *LedgerRepository) CreateEntry(ctx context.Context, entry *models.JournalEntry) error {
+	defer metrics.ObserveDBQuery("ledger", "CreateEntry")()
+
+	query := `
+		INSERT INTO ledger_entries (entry_type, transaction_id)
+		VALUES ($1, $2)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		entry.EntryType,
+		entry.TransactionID,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	postingQuery := `
+		INSERT INTO ledger_postings (entry_id, account_id, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		posting.EntryID = entry.ID
+		if err := r.db.QueryRowContext(ctx, postingQuery,
+			posting.EntryID,
+			posting.AccountID,
+			posting.Amount,
+		).Scan(&posting.ID); err != nil {
+			return translateError(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *LedgerRepository) GetBalance(ctx context.Context, accountID int64) (int, error) {
+	defer metrics.ObserveDBQuery("ledger", "GetBalance")()
+
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM ledger_postings
+		WHERE account_id = $1`
+
+	var balance int
+	if err := r.db.QueryRowContext(ctx, query, accountID).Scan(&balance); err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (r *LedgerRepository) GetBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error) {
+	defer metrics.ObserveDBQuery("ledger", "GetBalanceMismatches")()
+
+	query := `
+		SELECT u.id, u.username, u.coins, COALESCE(SUM(p.amount), 0)
+		FROM users u
+		LEFT JOIN ledger_accounts a ON a.user_id = u.id
+		LEFT JOIN ledger_postings p ON p.account_id = a.id
+		GROUP BY u.id, u.username, u.coins
+		HAVING u.coins <> COALESCE(SUM(p.amount), 0)
+		ORDER BY u.id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []*models.BalanceMismatch
+	for rows.Next() {
+		mismatch := &models.BalanceMismatch{}
+		if err := rows.Scan(
+			&mismatch.UserID,
+			&mismatch.Username,
+			&mismatch.StoredBalance,
+			&mismatch.ExpectedBalance,
+		); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
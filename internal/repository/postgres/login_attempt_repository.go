@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	db tracedDB
+}
+
+func NewLoginAttemptRepository(db DBTX) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: tracedDB{db}}
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, scope, key string) (*models.LoginAttempt, error) {
+	defer metrics.ObserveDBQuery("login_attempts", "Get")()
+
+	attempt := &models.LoginAttempt{}
+	query := `
+		SELECT scope, identifier, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE scope = $1 AND identifier = $2`
+
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(
+		&attempt.Scope,
+		&attempt.Key,
+		&attempt.Failures,
+		&attempt.LastFailureAt,
+		&attempt.LockedUntil,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, scope, key string, at, windowStart time.Time) (int, error) {
+	defer metrics.ObserveDBQuery("login_attempts", "RecordFailure")()
+
+	query := `
+		INSERT INTO login_attempts (scope, identifier, failures, last_failure_at)
+		VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, identifier) DO UPDATE
+		SET failures = CASE
+				WHEN login_attempts.last_failure_at < $4 THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING failures`
+
+	var failures int
+	err := r.db.QueryRowContext(ctx, query, scope, key, at.UTC(), windowStart.UTC()).Scan(&failures)
+	return failures, err
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, scope, key string, until time.Time) error {
+	defer metrics.ObserveDBQuery("login_attempts", "Lock")()
+
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $3
+		WHERE scope = $1 AND identifier = $2`
+
+	_, err := r.db.ExecContext(ctx, query, scope, key, until.UTC())
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, scope, key string) error {
+	defer metrics.ObserveDBQuery("login_attempts", "Reset")()
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE scope = $1 AND identifier = $2`, scope, key)
+	return err
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	defer metrics.ObserveDBQuery("login_attempts", "DeleteStale")()
+
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)`
+
+	_, err := r.db.ExecContext(ctx, query, before.UTC())
+	return err
+}
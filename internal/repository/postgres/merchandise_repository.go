@@ -2,22 +2,25 @@ package postgres
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"context"
 	"database/sql"
 )
 
 type MerchandiseRepository struct {
-	db *sql.DB
+	db tracedDB
 }
 
-func NewMerchandiseRepository(db *sql.DB) *MerchandiseRepository {
-	return &MerchandiseRepository{db: db}
+func NewMerchandiseRepository(db DBTX) *MerchandiseRepository {
+	return &MerchandiseRepository{db: tracedDB{db}}
 }
 
 func (r *MerchandiseRepository) GetByName(ctx context.Context, name string) (*models.Merchandise, error) {
+	defer metrics.ObserveDBQuery("merchandise", "GetByName")()
+
 	merchandise := &models.Merchandise{}
 	query := `
-		SELECT id, name, price
+		SELECT id, name, price, active
 		FROM merchandise
 		WHERE name = $1`
 
@@ -25,6 +28,7 @@ func (r *MerchandiseRepository) GetByName(ctx context.Context, name string) (*mo
 		&merchandise.ID,
 		&merchandise.Name,
 		&merchandise.Price,
+		&merchandise.Active,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -37,8 +41,10 @@ func (r *MerchandiseRepository) GetByName(ctx context.Context, name string) (*mo
 }
 
 func (r *MerchandiseRepository) GetAll(ctx context.Context) ([]*models.Merchandise, error) {
+	defer metrics.ObserveDBQuery("merchandise", "GetAll")()
+
 	query := `
-		SELECT id, name, price
+		SELECT id, name, price, active
 		FROM merchandise
 		ORDER BY name`
 
@@ -51,7 +57,7 @@ func (r *MerchandiseRepository) GetAll(ctx context.Context) ([]*models.Merchandi
 	var items []*models.Merchandise
 	for rows.Next() {
 		item := &models.Merchandise{}
-		if err := rows.Scan(&item.ID, &item.Name, &item.Price); err != nil {
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Active); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -63,3 +69,66 @@ func (r *MerchandiseRepository) GetAll(ctx context.Context) ([]*models.Merchandi
 
 	return items, nil
 }
+
+func (r *MerchandiseRepository) Create(ctx context.Context, item *models.Merchandise) error {
+	defer metrics.ObserveDBQuery("merchandise", "Create")()
+
+	query := `
+		INSERT INTO merchandise (name, price, active)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		item.Name,
+		item.Price,
+		item.Active,
+	).Scan(&item.ID)
+	return translateError(err)
+}
+
+func (r *MerchandiseRepository) Update(ctx context.Context, item *models.Merchandise) error {
+	defer metrics.ObserveDBQuery("merchandise", "Update")()
+
+	query := `
+		UPDATE merchandise
+		SET name = $1, price = $2, active = $3
+		WHERE id = $4`
+
+	result, err := r.db.ExecContext(ctx, query, item.Name, item.Price, item.Active, item.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *MerchandiseRepository) Delete(ctx context.Context, id int64) error {
+	defer metrics.ObserveDBQuery("merchandise", "Delete")()
+
+	query := `DELETE FROM merchandise WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type TokenRepository struct {
+	db tracedDB
+}
+
+func NewTokenRepository(db DBTX) *TokenRepository {
+	return &TokenRepository{db: tracedDB{db}}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	defer metrics.ObserveDBQuery("tokens", "CreateRefreshToken")()
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		token.UserID,
+		token.FamilyID,
+		token.TokenHash,
+		token.ExpiresAt.UTC(),
+	).Scan(&token.ID, &token.CreatedAt)
+	return translateError(err)
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer metrics.ObserveDBQuery("tokens", "GetRefreshToken")()
+
+	token := &models.RefreshToken{}
+	query := `
+		SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, id int64) error {
+	defer metrics.ObserveDBQuery("tokens", "RevokeRefreshToken")()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *TokenRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	defer metrics.ObserveDBQuery("tokens", "RevokeTokenFamily")()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := r.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	defer metrics.ObserveDBQuery("tokens", "RevokeAccessToken")()
+
+	query := `
+		INSERT INTO revoked_access_tokens (token_id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (token_id) DO NOTHING`
+
+	_, err := r.db.ExecContext(ctx, query, tokenID, expiresAt.UTC())
+	return err
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	defer metrics.ObserveDBQuery("tokens", "IsAccessTokenRevoked")()
+
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE token_id = $1)`
+
+	var revoked bool
+	if err := r.db.QueryRowContext(ctx, query, tokenID).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (r *TokenRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	defer metrics.ObserveDBQuery("tokens", "DeleteExpired")()
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, before.UTC()); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < $1`, before.UTC())
+	return err
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"avito-shop/internal/repository/sqlite"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracedDB only depends on database/sql, so its span handling is checked
+// against SQLite to run without a PostgreSQL server.
+func TestTracedDB_QueryContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prevProvider) })
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	traced := tracedDB{db}
+	ctx := context.Background()
+
+	t.Run("Drained", func(t *testing.T) {
+		before := len(recorder.Ended())
+
+		rows, err := traced.QueryContext(ctx, `SELECT 1 UNION ALL SELECT 2`)
+		if err != nil {
+			t.Fatalf("QueryContext() error = %v", err)
+		}
+		defer rows.Close()
+
+		var n int
+		for rows.Next() {
+			if len(recorder.Ended()) != before {
+				t.Fatal("Span ended before the rows were read")
+			}
+			n++
+		}
+		if n != 2 {
+			t.Errorf("Read %d rows, want 2", n)
+		}
+		if got := len(recorder.Ended()) - before; got != 1 {
+			t.Fatalf("Recorded %d spans after the rows were drained, want 1", got)
+		}
+
+		rows.Close()
+		if got := len(recorder.Ended()) - before; got != 1 {
+			t.Errorf("Recorded %d spans after Close, want 1", got)
+		}
+	})
+
+	t.Run("Closed early", func(t *testing.T) {
+		before := len(recorder.Ended())
+
+		rows, err := traced.QueryContext(ctx, `SELECT 1 UNION ALL SELECT 2`)
+		if err != nil {
+			t.Fatalf("QueryContext() error = %v", err)
+		}
+		rows.Next()
+		if len(recorder.Ended()) != before {
+			t.Fatal("Span ended before the rows were closed")
+		}
+
+		rows.Close()
+		if got := len(recorder.Ended()) - before; got != 1 {
+			t.Errorf("Recorded %d spans after Close, want 1", got)
+		}
+	})
+}
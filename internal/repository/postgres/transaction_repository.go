@@ -2,33 +2,40 @@ package postgres
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"context"
-	"database/sql"
+	"fmt"
+	"strings"
 )
 
 type TransactionRepository struct {
-	db *sql.DB
+	db tracedDB
 }
 
-func NewTransactionRepository(db *sql.DB) *TransactionRepository {
-	return &TransactionRepository{db: db}
+func NewTransactionRepository(db DBTX) *TransactionRepository {
+	return &TransactionRepository{db: tracedDB{db}}
 }
 
 func (r *TransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	defer metrics.ObserveDBQuery("transactions", "Create")()
+
 	query := `
 		INSERT INTO coin_transactions (from_user_id, to_user_id, amount, transaction_type)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at`
 
-	return r.db.QueryRowContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, query,
 		transaction.FromUserID,
 		transaction.ToUserID,
 		transaction.Amount,
 		transaction.TransactionType,
 	).Scan(&transaction.ID, &transaction.CreatedAt)
+	return translateError(err)
 }
 
 func (r *TransactionRepository) GetUserTransactions(ctx context.Context, userID int64) ([]*models.Transaction, error) {
+	defer metrics.ObserveDBQuery("transactions", "GetUserTransactions")()
+
 	query := `
 		SELECT id, from_user_id, to_user_id, amount, transaction_type, created_at
 		FROM coin_transactions
@@ -63,3 +70,162 @@ func (r *TransactionRepository) GetUserTransactions(ctx context.Context, userID
 
 	return transactions, nil
 }
+
+func (r *TransactionRepository) GetUserHistory(ctx context.Context, userID int64) ([]*models.TransactionDetails, error) {
+	defer metrics.ObserveDBQuery("transactions", "GetUserHistory")()
+
+	query := `
+		SELECT t.id, t.from_user_id, t.to_user_id, t.amount, t.transaction_type, t.created_at,
+			COALESCE(f.username, ''), COALESCE(r.username, '')
+		FROM coin_transactions t
+		LEFT JOIN users f ON f.id = t.from_user_id
+		LEFT JOIN users r ON r.id = t.to_user_id
+		WHERE t.from_user_id = $1 OR t.to_user_id = $1
+		ORDER BY t.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransactionDetails(rows)
+}
+
+func (r *TransactionRepository) ListUserHistory(ctx context.Context, filter models.TransactionFilter) ([]*models.TransactionDetails, error) {
+	defer metrics.ObserveDBQuery("transactions", "ListUserHistory")()
+
+	args := []interface{}{filter.UserID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var conditions []string
+	switch filter.Direction {
+	case models.DirectionSent:
+		conditions = append(conditions, "t.from_user_id = $1")
+	case models.DirectionReceived:
+		conditions = append(conditions, "t.to_user_id = $1")
+	default:
+		conditions = append(conditions, "(t.from_user_id = $1 OR t.to_user_id = $1)")
+	}
+
+	if filter.Type != "" {
+		conditions = append(conditions, "t.transaction_type = "+arg(filter.Type))
+	}
+	switch filter.Counterparty {
+	case "":
+	case models.ShopAccountName:
+		// Purchases are attributed to the shop, which is told apart by the
+		// transaction type rather than by name: a user may be called the same.
+		conditions = append(conditions, "t.from_user_id = $1 AND t.transaction_type = "+arg(models.TransactionTypePurchase))
+	default:
+		name := arg(filter.Counterparty)
+		conditions = append(conditions, fmt.Sprintf(
+			"((t.from_user_id = $1 AND r.username = %s) OR (t.to_user_id = $1 AND f.username = %s))",
+			name, name))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "t.created_at >= "+arg(filter.From.UTC()))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "t.created_at < "+arg(filter.To.UTC()))
+	}
+	if filter.BeforeID > 0 {
+		conditions = append(conditions, "t.id < "+arg(filter.BeforeID))
+	}
+
+	query := `
+		SELECT t.id, t.from_user_id, t.to_user_id, t.amount, t.transaction_type, t.created_at,
+			COALESCE(f.username, ''), COALESCE(r.username, '')
+		FROM coin_transactions t
+		LEFT JOIN users f ON f.id = t.from_user_id
+		LEFT JOIN users r ON r.id = t.to_user_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY t.id DESC
+		LIMIT ` + arg(filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransactionDetails(rows)
+}
+
+func scanTransactionDetails(rows *tracedRows) ([]*models.TransactionDetails, error) {
+	defer rows.Close()
+
+	var history []*models.TransactionDetails
+	for rows.Next() {
+		details := &models.TransactionDetails{}
+		if err := rows.Scan(
+			&details.ID,
+			&details.FromUserID,
+			&details.ToUserID,
+			&details.Amount,
+			&details.TransactionType,
+			&details.CreatedAt,
+			&details.FromUsername,
+			&details.ToUsername,
+		); err != nil {
+			return nil, err
+		}
+		history = append(history, details)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (r *TransactionRepository) GetHistoryMismatches(ctx context.Context, initialBalance int) ([]*models.BalanceMismatch, error) {
+	defer metrics.ObserveDBQuery("transactions", "GetHistoryMismatches")()
+
+	query := `
+		WITH movements AS (
+			SELECT to_user_id AS user_id, amount
+			FROM coin_transactions
+			WHERE to_user_id IS NOT NULL
+			UNION ALL
+			SELECT from_user_id, -amount
+			FROM coin_transactions
+		), history AS (
+			SELECT user_id, SUM(amount) AS delta
+			FROM movements
+			GROUP BY user_id
+		)
+		SELECT u.id, u.username, u.coins, $1 + COALESCE(h.delta, 0)
+		FROM users u
+		LEFT JOIN history h ON h.user_id = u.id
+		WHERE u.coins <> $1 + COALESCE(h.delta, 0)
+		ORDER BY u.id`
+
+	rows, err := r.db.QueryContext(ctx, query, initialBalance)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mismatches []*models.BalanceMismatch
+	for rows.Next() {
+		mismatch := &models.BalanceMismatch{}
+		if err := rows.Scan(
+			&mismatch.UserID,
+			&mismatch.Username,
+			&mismatch.StoredBalance,
+			&mismatch.ExpectedBalance,
+		); err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mismatches, nil
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/sqldb"
+	"database/sql"
+)
+
+// DBTX is what the repositories in this package run queries on: the pool or
+// a transaction. The repositories trace every query they run on it.
+type DBTX = sqldb.DBTX
+
+func NewRepositories(db DBTX) *repository.Repositories {
+	return &repository.Repositories{
+		Users:         NewUserRepository(db),
+		Merchandise:   NewMerchandiseRepository(db),
+		Transactions:  NewTransactionRepository(db),
+		Inventory:     NewUserInventoryRepository(db),
+		Idempotency:   NewIdempotencyRepository(db),
+		Ledger:        NewLedgerRepository(db),
+		Tokens:        NewTokenRepository(db),
+		LoginAttempts: NewLoginAttemptRepository(db),
+	}
+}
+
+func NewTxManager(db *sql.DB) *sqldb.TxManager {
+	return sqldb.NewTxManager(db, NewRepositories)
+}
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/repositorytest"
+	"avito-shop/internal/test"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) (*repository.Repositories, repository.TxManager) {
+		db, cleanup := test.SetupTestDB(t)
+		t.Cleanup(cleanup)
+		return NewRepositories(db), NewTxManager(db)
+	})
+}
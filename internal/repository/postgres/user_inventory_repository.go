@@ -2,28 +2,32 @@ package postgres
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"context"
-	"database/sql"
 )
 
 type UserInventoryRepository struct {
-	db *sql.DB
+	db tracedDB
 }
 
-func NewUserInventoryRepository(db *sql.DB) *UserInventoryRepository {
-	return &UserInventoryRepository{db: db}
+func NewUserInventoryRepository(db DBTX) *UserInventoryRepository {
+	return &UserInventoryRepository{db: tracedDB{db}}
 }
 
 func (r *UserInventoryRepository) AddItem(ctx context.Context, userID int64, merchandiseID int64) error {
+	defer metrics.ObserveDBQuery("inventory", "AddItem")()
+
 	query := `
 		INSERT INTO user_inventory (user_id, merchandise_id)
 		VALUES ($1, $2)`
 
 	_, err := r.db.ExecContext(ctx, query, userID, merchandiseID)
-	return err
+	return translateError(err)
 }
 
 func (r *UserInventoryRepository) GetUserItems(ctx context.Context, userID int64) ([]*models.InventoryItem, error) {
+	defer metrics.ObserveDBQuery("inventory", "GetUserItems")()
+
 	query := `
 		SELECT m.name, COUNT(ui.id)
 		FROM user_inventory ui
@@ -2,35 +2,45 @@ package postgres
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
+	"avito-shop/internal/repository"
 	"context"
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db tracedDB
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db DBTX) *UserRepository {
+	return &UserRepository{db: tracedDB{db}}
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	defer metrics.ObserveDBQuery("users", "Create")()
+
 	query := `
-		INSERT INTO users (username, password_hash, coins)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (username, password_hash, coins, role)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at`
 
-	return r.db.QueryRowContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, query,
 		user.Username,
 		user.PasswordHash,
 		user.Coins,
+		user.Role,
 	).Scan(&user.ID, &user.CreatedAt)
+	return translateError(err)
 }
 
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	defer metrics.ObserveDBQuery("users", "GetByUsername")()
+
 	var user models.User
-	query := `SELECT id, username, password_hash, coins FROM users WHERE username = $1`
-	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins)
+	query := `SELECT id, username, password_hash, coins, role FROM users WHERE username = $1`
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -41,9 +51,11 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*m
 }
 
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	defer metrics.ObserveDBQuery("users", "GetByID")()
+
 	var user models.User
-	query := `SELECT id, username, password_hash, coins FROM users WHERE id = $1`
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins)
+	query := `SELECT id, username, password_hash, coins, role FROM users WHERE id = $1`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -54,6 +66,8 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, e
 }
 
 func (r *UserRepository) UpdateCoins(ctx context.Context, userID int64, amount int) error {
+	defer metrics.ObserveDBQuery("users", "UpdateCoins")()
+
 	query := `
 		UPDATE users
 		SET coins = coins + $1
@@ -75,3 +89,118 @@ func (r *UserRepository) UpdateCoins(ctx context.Context, userID int64, amount i
 
 	return nil
 }
+
+func (r *UserRepository) UpdateRole(ctx context.Context, userID int64, role string) error {
+	defer metrics.ObserveDBQuery("users", "UpdateRole")()
+
+	query := `
+		UPDATE users
+		SET role = $1
+		WHERE id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *UserRepository) ListByRole(ctx context.Context, role string) ([]*models.User, error) {
+	defer metrics.ObserveDBQuery("users", "ListByRole")()
+
+	query := `
+		SELECT id, username, password_hash, coins, role
+		FROM users
+		WHERE role = $1
+		ORDER BY username`
+
+	rows, err := r.db.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) Debit(ctx context.Context, userID int64, amount int) error {
+	defer metrics.ObserveDBQuery("users", "Debit")()
+
+	query := `
+		UPDATE users
+		SET coins = coins - $1
+		WHERE id = $2 AND coins >= $1`
+
+	result, err := r.db.ExecContext(ctx, query, amount, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	var balance int
+	err = r.db.QueryRowContext(ctx, `SELECT coins FROM users WHERE id = $1`, userID).Scan(&balance)
+	if err != nil {
+		return err
+	}
+
+	return &repository.InsufficientFundsError{
+		UserID:  userID,
+		Balance: balance,
+		Amount:  amount,
+	}
+}
+
+func (r *UserRepository) LockForUpdate(ctx context.Context, userIDs ...int64) error {
+	defer metrics.ObserveDBQuery("users", "LockForUpdate")()
+
+	// The rows are sorted before they are locked, so concurrent callers
+	// acquire the locks in the same order.
+	query := `
+		SELECT id
+		FROM users
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+
+	return rows.Err()
+}
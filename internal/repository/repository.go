@@ -3,23 +3,75 @@ package repository
 import (
 	"avito-shop/internal/domain/models"
 	"context"
+	"errors"
+	"fmt"
+	"time"
 )
 
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrDuplicate is returned when a write violates a uniqueness constraint.
+	ErrDuplicate = errors.New("duplicate key")
+	// ErrReferenced is returned when a row cannot be deleted because other
+	// rows still reference it, or when a write refers to a missing row.
+	ErrReferenced = errors.New("row is still referenced")
+)
+
+// InsufficientFundsError is returned by UserRepository.Debit when the
+// balance is lower than the requested amount. It matches
+// ErrInsufficientFunds with errors.Is.
+type InsufficientFundsError struct {
+	UserID  int64
+	Balance int
+	Amount  int
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds: have %d, need %d", e.Balance, e.Amount)
+}
+
+func (e *InsufficientFundsError) Unwrap() error {
+	return ErrInsufficientFunds
+}
+
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	GetByUsername(ctx context.Context, username string) (*models.User, error)
 	UpdateCoins(ctx context.Context, userID int64, amount int) error
+	// Debit atomically subtracts amount from the balance, refusing to take
+	// it below zero. Concurrent debits of one account cannot overdraw it.
+	Debit(ctx context.Context, userID int64, amount int) error
 	GetByID(ctx context.Context, id int64) (*models.User, error)
+	UpdateRole(ctx context.Context, userID int64, role string) error
+	// LockForUpdate locks the rows of userIDs until the transaction ends,
+	// always in ascending ID order, so that transactions touching the same
+	// users cannot deadlock. Unknown IDs are ignored.
+	LockForUpdate(ctx context.Context, userIDs ...int64) error
+	// ListByRole returns the users with role, ordered by username.
+	ListByRole(ctx context.Context, role string) ([]*models.User, error)
 }
 
 type MerchandiseRepository interface {
 	GetByName(ctx context.Context, name string) (*models.Merchandise, error)
 	GetAll(ctx context.Context) ([]*models.Merchandise, error)
+	Create(ctx context.Context, item *models.Merchandise) error
+	Update(ctx context.Context, item *models.Merchandise) error
+	Delete(ctx context.Context, id int64) error
 }
 
 type TransactionRepository interface {
 	Create(ctx context.Context, transaction *models.Transaction) error
 	GetUserTransactions(ctx context.Context, userID int64) ([]*models.Transaction, error)
+	// GetUserHistory returns the same rows as GetUserTransactions with the
+	// counterparty usernames joined in a single query.
+	GetUserHistory(ctx context.Context, userID int64) ([]*models.TransactionDetails, error)
+	// ListUserHistory returns the transactions matching filter ordered by id,
+	// newest first.
+	ListUserHistory(ctx context.Context, filter models.TransactionFilter) ([]*models.TransactionDetails, error)
+	// GetHistoryMismatches lists users whose stored coins differ from
+	// initialBalance plus everything they received minus everything they
+	// spent according to the transaction history.
+	GetHistoryMismatches(ctx context.Context, initialBalance int) ([]*models.BalanceMismatch, error)
 }
 
 type UserInventoryRepository interface {
@@ -27,9 +79,78 @@ type UserInventoryRepository interface {
 	GetUserItems(ctx context.Context, userID int64) ([]*models.InventoryItem, error)
 }
 
+type IdempotencyRepository interface {
+	// Reserve inserts key unless the user already has a record with the same
+	// key, in which case it returns false and leaves the table untouched.
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, userID int64, key string) (*models.IdempotencyKey, error)
+	SaveResponse(ctx context.Context, id int64, status int, body []byte) error
+	Delete(ctx context.Context, id int64) error
+	// DeleteExpired removes keys created before the given time, whether or
+	// not their response was stored.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+type LedgerRepository interface {
+	CreateAccount(ctx context.Context, account *models.LedgerAccount) error
+	GetUserAccount(ctx context.Context, userID int64) (*models.LedgerAccount, error)
+	GetSystemAccount(ctx context.Context, accountType string) (*models.LedgerAccount, error)
+	// CreateEntry stores the entry with its postings. It must run inside a
+	// transaction so that a partially written entry is never visible.
+	CreateEntry(ctx context.Context, entry *models.JournalEntry) error
+	GetBalance(ctx context.Context, accountID int64) (int, error)
+	// GetBalanceMismatches lists users whose stored coins differ from the
+	// balance of their ledger account.
+	GetBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error)
+}
+
+// TokenRepository stores refresh tokens and the IDs of revoked access
+// tokens. Lookups of missing rows return nil, nil.
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// RevokeRefreshToken revokes the token if it is still active and
+	// returns sql.ErrNoRows otherwise, so that of two concurrent refreshes
+	// with the same token only one succeeds.
+	RevokeRefreshToken(ctx context.Context, id int64) error
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	// RevokeAccessToken records tokenID as revoked until expiresAt; revoking
+	// it again is not an error.
+	RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	// DeleteExpired removes refresh tokens and revocations that expired
+	// before the given time.
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// LoginAttemptRepository persists failed login counters for brute-force
+// protection, keyed by scope (see models.LoginScopeUsername) and key.
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, scope, key string) (*models.LoginAttempt, error)
+	// RecordFailure counts a failure at the given time and returns the new
+	// number of failures. A counter whose last failure is older than
+	// windowStart starts again from one.
+	RecordFailure(ctx context.Context, scope, key string, at, windowStart time.Time) (int, error)
+	Lock(ctx context.Context, scope, key string, until time.Time) error
+	Reset(ctx context.Context, scope, key string) error
+	// DeleteStale removes counters that have neither failed nor been locked
+	// since the given time.
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
 type Repositories struct {
-	Users        UserRepository
-	Merchandise  MerchandiseRepository
-	Transactions TransactionRepository
-	Inventory    UserInventoryRepository
+	Users         UserRepository
+	Merchandise   MerchandiseRepository
+	Transactions  TransactionRepository
+	Inventory     UserInventoryRepository
+	Idempotency   IdempotencyRepository
+	Ledger        LedgerRepository
+	Tokens        TokenRepository
+	LoginAttempts LoginAttemptRepository
+}
+
+// TxManager runs a unit of work atomically: the repositories handed to fn
+// share one transaction that is committed only when fn returns nil.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(repos *Repositories) error) error
 }
@@ -0,0 +1,807 @@
+// Package repositorytest holds the conformance suite every implementation of
+// the repository interfaces must pass, so that the backends stay
+// interchangeable.
+package repositorytest
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns repositories over an empty store together with the
+// transaction manager for that store.
+type Factory func(t *testing.T) (*repository.Repositories, repository.TxManager)
+
+// Run runs the conformance suite, calling newStore once per test.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repos *repository.Repositories, tx repository.TxManager)
+	}{
+		{"Users", testUsers},
+		{"Debit", testDebit},
+		{"LockForUpdate", testLockForUpdate},
+		{"Merchandise", testMerchandise},
+		{"Inventory", testInventory},
+		{"Transactions", testTransactions},
+		{"TransactionFilter", testTransactionFilter},
+		{"HistoryMismatches", testHistoryMismatches},
+		{"Idempotency", testIdempotency},
+		{"Ledger", testLedger},
+		{"Tokens", testTokens},
+		{"LoginAttempts", testLoginAttempts},
+		{"Commit", testCommit},
+		{"Rollback", testRollback},
+		{"ConcurrentDebits", testConcurrentDebits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos, tx := newStore(t)
+			tt.fn(t, repos, tx)
+		})
+	}
+}
+
+func createUser(t *testing.T, repos *repository.Repositories, username string, coins int) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: "hash",
+		Coins:        coins,
+		Role:         models.RoleUser,
+	}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Failed to create user %s: %v", username, err)
+	}
+	return user
+}
+
+func createItem(t *testing.T, repos *repository.Repositories, name string, price int) *models.Merchandise {
+	t.Helper()
+
+	item := &models.Merchandise{Name: name, Price: price, Active: true}
+	if err := repos.Merchandise.Create(context.Background(), item); err != nil {
+		t.Fatalf("Failed to create item %s: %v", name, err)
+	}
+	return item
+}
+
+func createTransaction(t *testing.T, repos *repository.Repositories, from int64, to *int64, amount int) *models.Transaction {
+	t.Helper()
+
+	transactionType := models.TransactionTypeTransfer
+	if to == nil {
+		transactionType = models.TransactionTypePurchase
+	}
+	transaction := &models.Transaction{
+		FromUserID:      from,
+		ToUserID:        to,
+		Amount:          amount,
+		TransactionType: transactionType,
+	}
+	if err := repos.Transactions.Create(context.Background(), transaction); err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	return transaction
+}
+
+func testUsers(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice", 1000)
+	if alice.ID == 0 || alice.CreatedAt.IsZero() {
+		t.Errorf("Create() did not fill ID and CreatedAt: %+v", alice)
+	}
+
+	err := repos.Users.Create(ctx, &models.User{Username: "alice", PasswordHash: "hash", Role: models.RoleUser})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Create() duplicate error = %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	byName, err := repos.Users.GetByUsername(ctx, "alice")
+	if err != nil || byName == nil || byName.ID != alice.ID || byName.Coins != 1000 || byName.Role != models.RoleUser {
+		t.Errorf("GetByUsername() = %+v, %v", byName, err)
+	}
+
+	byID, err := repos.Users.GetByID(ctx, alice.ID)
+	if err != nil || byID == nil || byID.Username != "alice" || byID.PasswordHash != "hash" {
+		t.Errorf("GetByID() = %+v, %v", byID, err)
+	}
+
+	if missing, err := repos.Users.GetByUsername(ctx, "nobody"); missing != nil || err != nil {
+		t.Errorf("GetByUsername() of missing user = %+v, %v, want nil, nil", missing, err)
+	}
+	if missing, err := repos.Users.GetByID(ctx, alice.ID+1000); missing != nil || err != nil {
+		t.Errorf("GetByID() of missing user = %+v, %v, want nil, nil", missing, err)
+	}
+
+	if err := repos.Users.UpdateCoins(ctx, alice.ID, -250); err != nil {
+		t.Fatalf("UpdateCoins() error = %v", err)
+	}
+	if err := repos.Users.UpdateRole(ctx, alice.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("UpdateRole() error = %v", err)
+	}
+	updated, _ := repos.Users.GetByID(ctx, alice.ID)
+	if updated.Coins != 750 || updated.Role != models.RoleAdmin {
+		t.Errorf("after updates got coins %d role %q, want 750 %q", updated.Coins, updated.Role, models.RoleAdmin)
+	}
+
+	createUser(t, repos, "bob", 1000)
+	admins, err := repos.Users.ListByRole(ctx, models.RoleAdmin)
+	if err != nil || len(admins) != 1 || admins[0].Username != "alice" {
+		t.Errorf("ListByRole(admin) = %v, %v, want [alice]", admins, err)
+	}
+	users, err := repos.Users.ListByRole(ctx, models.RoleUser)
+	if err != nil || len(users) != 1 || users[0].Username != "bob" {
+		t.Errorf("ListByRole(user) = %v, %v, want [bob]", users, err)
+	}
+
+	if err := repos.Users.UpdateCoins(ctx, alice.ID+1000, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateCoins() of missing user error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := repos.Users.UpdateRole(ctx, alice.ID+1000, models.RoleAdmin); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateRole() of missing user error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func testDebit(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+	user := createUser(t, repos, "alice", 100)
+
+	if err := repos.Users.Debit(ctx, user.ID, 60); err != nil {
+		t.Fatalf("Debit() error = %v", err)
+	}
+
+	err := repos.Users.Debit(ctx, user.ID, 60)
+	var insufficient *repository.InsufficientFundsError
+	if !errors.As(err, &insufficient) || !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Fatalf("Debit() error = %v, want InsufficientFundsError", err)
+	}
+	if insufficient.Balance != 40 || insufficient.Amount != 60 {
+		t.Errorf("InsufficientFundsError = %+v, want balance 40 amount 60", insufficient)
+	}
+
+	if err := repos.Users.Debit(ctx, user.ID, 40); err != nil {
+		t.Errorf("Debit() of the whole balance error = %v", err)
+	}
+	if err := repos.Users.Debit(ctx, user.ID+1000, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Debit() of missing user error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	got, _ := repos.Users.GetByID(ctx, user.ID)
+	if got.Coins != 0 {
+		t.Errorf("coins = %d, want 0", got.Coins)
+	}
+}
+
+func testLockForUpdate(t *testing.T, repos *repository.Repositories, txManager repository.TxManager) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice", 100)
+	bob := createUser(t, repos, "bob", 100)
+
+	err := txManager.WithinTx(ctx, func(txRepos *repository.Repositories) error {
+		if err := txRepos.Users.LockForUpdate(ctx, bob.ID, alice.ID, bob.ID+1000); err != nil {
+			return err
+		}
+		return txRepos.Users.Debit(ctx, bob.ID, 10)
+	})
+	if err != nil {
+		t.Fatalf("LockForUpdate() in transaction error = %v", err)
+	}
+	if got, _ := repos.Users.GetByID(ctx, bob.ID); got.Coins != 90 {
+		t.Errorf("coins after locked debit = %d, want 90", got.Coins)
+	}
+}
+
+func testMerchandise(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	pen := createItem(t, repos, "pen", 10)
+	cup := createItem(t, repos, "cup", 20)
+
+	if err := repos.Merchandise.Create(ctx, &models.Merchandise{Name: "pen", Price: 5, Active: true}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Create() duplicate error = %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	items, err := repos.Merchandise.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	if len(items) != 2 || items[0].Name != "cup" || items[1].Name != "pen" {
+		t.Errorf("GetAll() = %+v, want cup and pen ordered by name", items)
+	}
+
+	if missing, err := repos.Merchandise.GetByName(ctx, "hat"); missing != nil || err != nil {
+		t.Errorf("GetByName() of missing item = %+v, %v, want nil, nil", missing, err)
+	}
+
+	pen.Price = 15
+	pen.Active = false
+	if err := repos.Merchandise.Update(ctx, pen); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := repos.Merchandise.GetByName(ctx, "pen")
+	if err != nil || got == nil || got.Price != 15 || got.Active {
+		t.Errorf("GetByName() after update = %+v, %v", got, err)
+	}
+
+	renamed := *cup
+	renamed.Name = "pen"
+	if err := repos.Merchandise.Update(ctx, &renamed); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Update() to a taken name error = %v, want %v", err, repository.ErrDuplicate)
+	}
+	if err := repos.Merchandise.Update(ctx, &models.Merchandise{ID: cup.ID + 1000, Name: "hat", Price: 1}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Update() of missing item error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	user := createUser(t, repos, "alice", 1000)
+	if err := repos.Inventory.AddItem(ctx, user.ID, cup.ID); err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+	if err := repos.Merchandise.Delete(ctx, cup.ID); !errors.Is(err, repository.ErrReferenced) {
+		t.Errorf("Delete() of purchased item error = %v, want %v", err, repository.ErrReferenced)
+	}
+
+	if err := repos.Merchandise.Delete(ctx, pen.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := repos.Merchandise.Delete(ctx, pen.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Delete() of missing item error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
+
+func testInventory(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice", 1000)
+	bob := createUser(t, repos, "bob", 1000)
+	pen := createItem(t, repos, "pen", 10)
+	cup := createItem(t, repos, "cup", 20)
+
+	for _, id := range []int64{pen.ID, cup.ID, pen.ID} {
+		if err := repos.Inventory.AddItem(ctx, alice.ID, id); err != nil {
+			t.Fatalf("AddItem() error = %v", err)
+		}
+	}
+
+	items, err := repos.Inventory.GetUserItems(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserItems() error = %v", err)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Type < items[j].Type })
+	if len(items) != 2 || *items[0] != (models.InventoryItem{Type: "cup", Quantity: 1}) || *items[1] != (models.InventoryItem{Type: "pen", Quantity: 2}) {
+		t.Errorf("GetUserItems() = %+v, want cup x1 and pen x2", items)
+	}
+
+	if items, err := repos.Inventory.GetUserItems(ctx, bob.ID); err != nil || len(items) != 0 {
+		t.Errorf("GetUserItems() of empty inventory = %+v, %v", items, err)
+	}
+
+	if err := repos.Inventory.AddItem(ctx, alice.ID, cup.ID+1000); !errors.Is(err, repository.ErrReferenced) {
+		t.Errorf("AddItem() of missing item error = %v, want %v", err, repository.ErrReferenced)
+	}
+}
+
+func testTransactions(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice", 1000)
+	bob := createUser(t, repos, "bob", 1000)
+	carol := createUser(t, repos, "carol", 1000)
+
+	sent := createTransaction(t, repos, alice.ID, &bob.ID, 100)
+	if sent.ID == 0 || sent.CreatedAt.IsZero() {
+		t.Errorf("Create() did not fill ID and CreatedAt: %+v", sent)
+	}
+	createTransaction(t, repos, bob.ID, &carol.ID, 50)
+	createTransaction(t, repos, carol.ID, &alice.ID, 25)
+	createTransaction(t, repos, alice.ID, nil, 80)
+
+	missing := alice.ID + 1000
+	err := repos.Transactions.Create(ctx, &models.Transaction{FromUserID: alice.ID, ToUserID: &missing, Amount: 1, TransactionType: models.TransactionTypeTransfer})
+	if !errors.Is(err, repository.ErrReferenced) {
+		t.Errorf("Create() to missing user error = %v, want %v", err, repository.ErrReferenced)
+	}
+
+	transactions, err := repos.Transactions.GetUserTransactions(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserTransactions() error = %v", err)
+	}
+	if len(transactions) != 3 {
+		t.Errorf("GetUserTransactions() returned %d transactions, want 3", len(transactions))
+	}
+
+	history, err := repos.Transactions.GetUserHistory(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserHistory() error = %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("GetUserHistory() returned %d transactions, want 3", len(history))
+	}
+	for _, d := range history {
+		switch {
+		case d.ToUserID == nil:
+			if d.FromUsername != "alice" || d.ToUsername != "" || d.Amount != 80 {
+				t.Errorf("purchase details = %+v", d)
+			}
+		case *d.ToUserID == bob.ID:
+			if d.FromUsername != "alice" || d.ToUsername != "bob" || d.Amount != 100 {
+				t.Errorf("sent transfer details = %+v", d)
+			}
+		case *d.ToUserID == alice.ID:
+			if d.FromUsername != "carol" || d.ToUsername != "alice" || d.Amount != 25 {
+				t.Errorf("received transfer details = %+v", d)
+			}
+		default:
+			t.Errorf("unexpected transaction in history: %+v", d)
+		}
+	}
+}
+
+func testTransactionFilter(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice", 1000)
+	bob := createUser(t, repos, "bob", 1000)
+
+	t1 := createTransaction(t, repos, alice.ID, &bob.ID, 10)
+	t2 := createTransaction(t, repos, bob.ID, &alice.ID, 20)
+	t3 := createTransaction(t, repos, alice.ID, nil, 30)
+	t4 := createTransaction(t, repos, alice.ID, &bob.ID, 40)
+
+	// A user whose name matches the shop's must not be confused with it.
+	shopUser := createUser(t, repos, models.ShopAccountName, 1000)
+	t5 := createTransaction(t, repos, alice.ID, &shopUser.ID, 50)
+
+	tests := []struct {
+		name   string
+		filter models.TransactionFilter
+		want   []int64
+	}{
+		{"all newest first", models.TransactionFilter{}, []int64{t5.ID, t4.ID, t3.ID, t2.ID, t1.ID}},
+		{"sent", models.TransactionFilter{Direction: models.DirectionSent}, []int64{t5.ID, t4.ID, t3.ID, t1.ID}},
+		{"received", models.TransactionFilter{Direction: models.DirectionReceived}, []int64{t2.ID}},
+		{"purchases", models.TransactionFilter{Type: models.TransactionTypePurchase}, []int64{t3.ID}},
+		{"counterparty", models.TransactionFilter{Counterparty: "bob"}, []int64{t4.ID, t2.ID, t1.ID}},
+		{"shop counterparty", models.TransactionFilter{Counterparty: models.ShopAccountName}, []int64{t3.ID}},
+		{"before cursor", models.TransactionFilter{BeforeID: t3.ID}, []int64{t2.ID, t1.ID}},
+		{"limit", models.TransactionFilter{Limit: 2}, []int64{t5.ID, t4.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			filter.UserID = alice.ID
+			if filter.Limit == 0 {
+				filter.Limit = 10
+			}
+
+			history, err := repos.Transactions.ListUserHistory(ctx, filter)
+			if err != nil {
+				t.Fatalf("ListUserHistory() error = %v", err)
+			}
+
+			var got []int64
+			for _, d := range history {
+				got = append(got, d.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ListUserHistory() ids = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("ListUserHistory() ids = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func testHistoryMismatches(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+
+	alice := createUser(t, repos, "alice", 900)
+	bob := createUser(t, repos, "bob", 1100)
+	createTransaction(t, repos, alice.ID, &bob.ID, 100)
+
+	mismatches, err := repos.Transactions.GetHistoryMismatches(ctx, 1000)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("GetHistoryMismatches() = %+v, %v, want none", mismatches, err)
+	}
+
+	if err := repos.Users.UpdateCoins(ctx, bob.ID, 5); err != nil {
+		t.Fatalf("UpdateCoins() error = %v", err)
+	}
+
+	mismatches, err = repos.Transactions.GetHistoryMismatches(ctx, 1000)
+	if err != nil {
+		t.Fatalf("GetHistoryMismatches() error = %v", err)
+	}
+	want := models.BalanceMismatch{UserID: bob.ID, Username: "bob", StoredBalance: 1105, ExpectedBalance: 1100}
+	if len(mismatches) != 1 || *mismatches[0] != want {
+		t.Errorf("GetHistoryMismatches() = %+v, want %+v", mismatches, want)
+	}
+}
+
+func testIdempotency(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+	user := createUser(t, repos, "alice", 1000)
+
+	key := &models.IdempotencyKey{UserID: user.ID, Key: "key-1", RequestHash: "hash"}
+	reserved, err := repos.Idempotency.Reserve(ctx, key)
+	if err != nil || !reserved || key.ID == 0 {
+		t.Fatalf("Reserve() = %v, %v, id %d", reserved, err, key.ID)
+	}
+
+	reserved, err = repos.Idempotency.Reserve(ctx, &models.IdempotencyKey{UserID: user.ID, Key: "key-1", RequestHash: "other"})
+	if err != nil || reserved {
+		t.Errorf("Reserve() of taken key = %v, %v, want false, nil", reserved, err)
+	}
+
+	record, err := repos.Idempotency.Get(ctx, user.ID, "key-1")
+	if err != nil || record == nil || record.RequestHash != "hash" || record.Completed() {
+		t.Fatalf("Get() = %+v, %v", record, err)
+	}
+
+	if err := repos.Idempotency.SaveResponse(ctx, key.ID, 200, []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("SaveResponse() error = %v", err)
+	}
+	record, err = repos.Idempotency.Get(ctx, user.ID, "key-1")
+	if err != nil || record == nil || !record.Completed() || *record.ResponseStatus != 200 || string(record.ResponseBody) != `{"ok":true}` {
+		t.Errorf("Get() after SaveResponse = %+v, %v", record, err)
+	}
+
+	if err := repos.Idempotency.SaveResponse(ctx, key.ID+1000, 200, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("SaveResponse() of missing key error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	if err := repos.Idempotency.Delete(ctx, key.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if record, err := repos.Idempotency.Get(ctx, user.ID, "key-1"); record != nil || err != nil {
+		t.Errorf("Get() after Delete = %+v, %v, want nil, nil", record, err)
+	}
+
+	key = &models.IdempotencyKey{UserID: user.ID, Key: "key-2", RequestHash: "hash"}
+	if reserved, err := repos.Idempotency.Reserve(ctx, key); err != nil || !reserved {
+		t.Fatalf("Reserve() = %v, %v", reserved, err)
+	}
+	if err := repos.Idempotency.DeleteExpired(ctx, key.CreatedAt.Add(-time.Second)); err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if record, _ := repos.Idempotency.Get(ctx, user.ID, "key-2"); record == nil {
+		t.Error("DeleteExpired() removed a recent key")
+	}
+	if err := repos.Idempotency.DeleteExpired(ctx, key.CreatedAt.Add(time.Second)); err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if record, _ := repos.Idempotency.Get(ctx, user.ID, "key-2"); record != nil {
+		t.Errorf("Get() after DeleteExpired = %+v, want nil", record)
+	}
+}
+
+func testLedger(t *testing.T, repos *repository.Repositories, txManager repository.TxManager) {
+	ctx := context.Background()
+	user := createUser(t, repos, "alice", 100)
+
+	issuance, err := repos.Ledger.GetSystemAccount(ctx, models.AccountTypeIssuance)
+	if err != nil || issuance == nil {
+		t.Fatalf("GetSystemAccount() = %+v, %v", issuance, err)
+	}
+	if shop, err := repos.Ledger.GetSystemAccount(ctx, models.AccountTypeShop); err != nil || shop == nil {
+		t.Fatalf("GetSystemAccount() of the shop = %+v, %v", shop, err)
+	}
+
+	account := &models.LedgerAccount{AccountType: models.AccountTypeUser, UserID: &user.ID}
+	if err := repos.Ledger.CreateAccount(ctx, account); err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+	err = repos.Ledger.CreateAccount(ctx, &models.LedgerAccount{AccountType: models.AccountTypeUser, UserID: &user.ID})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("CreateAccount() duplicate error = %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	got, err := repos.Ledger.GetUserAccount(ctx, user.ID)
+	if err != nil || got == nil || got.ID != account.ID {
+		t.Fatalf("GetUserAccount() = %+v, %v", got, err)
+	}
+	if missing, err := repos.Ledger.GetUserAccount(ctx, user.ID+1000); missing != nil || err != nil {
+		t.Errorf("GetUserAccount() of missing user = %+v, %v, want nil, nil", missing, err)
+	}
+
+	mismatches, err := repos.Ledger.GetBalanceMismatches(ctx)
+	if err != nil || len(mismatches) != 1 || mismatches[0].ExpectedBalance != 0 {
+		t.Errorf("GetBalanceMismatches() before grant = %+v, %v", mismatches, err)
+	}
+
+	err = txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		return repos.Ledger.CreateEntry(ctx, &models.JournalEntry{
+			EntryType: models.EntryTypeGrant,
+			Postings: []models.Posting{
+				{AccountID: account.ID, Amount: 100},
+				{AccountID: issuance.ID, Amount: -100},
+			},
+		})
+	})
+	if err != nil {
+		t.Fatalf("CreateEntry() error = %v", err)
+	}
+
+	if balance, err := repos.Ledger.GetBalance(ctx, account.ID); err != nil || balance != 100 {
+		t.Errorf("GetBalance() = %d, %v, want 100", balance, err)
+	}
+	if mismatches, err := repos.Ledger.GetBalanceMismatches(ctx); err != nil || len(mismatches) != 0 {
+		t.Errorf("GetBalanceMismatches() = %+v, %v, want none", mismatches, err)
+	}
+
+	err = txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		return repos.Ledger.CreateEntry(ctx, &models.JournalEntry{
+			EntryType: models.EntryTypeAdjustment,
+			Postings: []models.Posting{
+				{AccountID: account.ID, Amount: 50},
+				{AccountID: issuance.ID, Amount: -10},
+			},
+		})
+	})
+	if err == nil {
+		t.Error("CreateEntry() of an unbalanced entry succeeded")
+	}
+	if balance, _ := repos.Ledger.GetBalance(ctx, account.ID); balance != 100 {
+		t.Errorf("GetBalance() after rejected entry = %d, want 100", balance)
+	}
+}
+
+func testTokens(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+	user := createUser(t, repos, "alice", 1000)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+
+	first := &models.RefreshToken{UserID: user.ID, FamilyID: "family", TokenHash: "hash-1", ExpiresAt: expiresAt}
+	if err := repos.Tokens.CreateRefreshToken(ctx, first); err != nil {
+		t.Fatalf("CreateRefreshToken() error = %v", err)
+	}
+	if first.ID == 0 {
+		t.Error("CreateRefreshToken() did not fill ID")
+	}
+	second := &models.RefreshToken{UserID: user.ID, FamilyID: "family", TokenHash: "hash-2", ExpiresAt: expiresAt}
+	if err := repos.Tokens.CreateRefreshToken(ctx, second); err != nil {
+		t.Fatalf("CreateRefreshToken() error = %v", err)
+	}
+	err := repos.Tokens.CreateRefreshToken(ctx, &models.RefreshToken{UserID: user.ID, FamilyID: "other", TokenHash: "hash-1", ExpiresAt: expiresAt})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("CreateRefreshToken() duplicate error = %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	got, err := repos.Tokens.GetRefreshToken(ctx, "hash-1")
+	if err != nil || got == nil || got.ID != first.ID || got.UserID != user.ID || got.FamilyID != "family" || got.RevokedAt != nil {
+		t.Fatalf("GetRefreshToken() = %+v, %v", got, err)
+	}
+	if !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, expiresAt)
+	}
+	if missing, err := repos.Tokens.GetRefreshToken(ctx, "unknown"); missing != nil || err != nil {
+		t.Errorf("GetRefreshToken() of missing token = %+v, %v, want nil, nil", missing, err)
+	}
+
+	if err := repos.Tokens.RevokeRefreshToken(ctx, first.ID); err != nil {
+		t.Fatalf("RevokeRefreshToken() error = %v", err)
+	}
+	if err := repos.Tokens.RevokeRefreshToken(ctx, first.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RevokeRefreshToken() twice error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if got, _ := repos.Tokens.GetRefreshToken(ctx, "hash-1"); got == nil || got.RevokedAt == nil {
+		t.Errorf("GetRefreshToken() after revoke = %+v, want RevokedAt set", got)
+	}
+
+	if err := repos.Tokens.RevokeTokenFamily(ctx, "family"); err != nil {
+		t.Fatalf("RevokeTokenFamily() error = %v", err)
+	}
+	if got, _ := repos.Tokens.GetRefreshToken(ctx, "hash-2"); got == nil || got.RevokedAt == nil {
+		t.Errorf("GetRefreshToken() after family revoke = %+v, want RevokedAt set", got)
+	}
+
+	if revoked, err := repos.Tokens.IsAccessTokenRevoked(ctx, "jti"); err != nil || revoked {
+		t.Errorf("IsAccessTokenRevoked() = %v, %v, want false", revoked, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := repos.Tokens.RevokeAccessToken(ctx, "jti", expiresAt); err != nil {
+			t.Fatalf("RevokeAccessToken() error = %v", err)
+		}
+	}
+	if revoked, err := repos.Tokens.IsAccessTokenRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Errorf("IsAccessTokenRevoked() = %v, %v, want true", revoked, err)
+	}
+
+	if err := repos.Tokens.DeleteExpired(ctx, expiresAt.Add(time.Second)); err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if got, _ := repos.Tokens.GetRefreshToken(ctx, "hash-1"); got != nil {
+		t.Errorf("GetRefreshToken() after DeleteExpired = %+v, want nil", got)
+	}
+	if revoked, _ := repos.Tokens.IsAccessTokenRevoked(ctx, "jti"); revoked {
+		t.Error("IsAccessTokenRevoked() after DeleteExpired = true, want false")
+	}
+}
+
+func testLoginAttempts(t *testing.T, repos *repository.Repositories, _ repository.TxManager) {
+	ctx := context.Background()
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	scope := models.LoginScopeUsername
+
+	if got, err := repos.LoginAttempts.Get(ctx, scope, "alice"); got != nil || err != nil {
+		t.Errorf("Get() of unknown key = %+v, %v, want nil, nil", got, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		failures, err := repos.LoginAttempts.RecordFailure(ctx, scope, "alice", start.Add(time.Duration(i)*time.Second), start)
+		if err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+		if failures != i {
+			t.Errorf("RecordFailure() = %d, want %d", failures, i)
+		}
+	}
+	if failures, _ := repos.LoginAttempts.RecordFailure(ctx, models.LoginScopeIP, "alice", start, start); failures != 1 {
+		t.Errorf("RecordFailure() in other scope = %d, want 1", failures)
+	}
+
+	lockedUntil := start.Add(time.Minute)
+	if err := repos.LoginAttempts.Lock(ctx, scope, "alice", lockedUntil); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	got, err := repos.LoginAttempts.Get(ctx, scope, "alice")
+	if err != nil || got == nil || got.Failures != 3 || got.LockedUntil == nil || !got.LockedUntil.Equal(lockedUntil) {
+		t.Fatalf("Get() after Lock = %+v, %v", got, err)
+	}
+	if !got.LastFailureAt.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("LastFailureAt = %v, want %v", got.LastFailureAt, start.Add(3*time.Second))
+	}
+	if !got.Locked(start) || got.Locked(lockedUntil) {
+		t.Errorf("Locked() does not honour LockedUntil %v", got.LockedUntil)
+	}
+
+	// A failure after the window restarts the count.
+	later := start.Add(time.Hour)
+	if failures, _ := repos.LoginAttempts.RecordFailure(ctx, scope, "alice", later, later.Add(-time.Minute)); failures != 1 {
+		t.Errorf("RecordFailure() after window = %d, want 1", failures)
+	}
+
+	if err := repos.LoginAttempts.DeleteStale(ctx, later); err != nil {
+		t.Fatalf("DeleteStale() error = %v", err)
+	}
+	if got, _ := repos.LoginAttempts.Get(ctx, models.LoginScopeIP, "alice"); got != nil {
+		t.Errorf("Get() of stale key = %+v, want nil", got)
+	}
+	if got, _ := repos.LoginAttempts.Get(ctx, scope, "alice"); got == nil {
+		t.Error("DeleteStale() removed a recent key")
+	}
+
+	if err := repos.LoginAttempts.Reset(ctx, scope, "alice"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if got, _ := repos.LoginAttempts.Get(ctx, scope, "alice"); got != nil {
+		t.Errorf("Get() after Reset = %+v, want nil", got)
+	}
+}
+
+func testCommit(t *testing.T, repos *repository.Repositories, txManager repository.TxManager) {
+	ctx := context.Background()
+
+	err := txManager.WithinTx(ctx, func(txRepos *repository.Repositories) error {
+		user := &models.User{Username: "alice", PasswordHash: "hash", Coins: 1000, Role: models.RoleUser}
+		if err := txRepos.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		// Writes are visible inside the transaction before it commits.
+		got, err := txRepos.Users.GetByUsername(ctx, "alice")
+		if err != nil || got == nil {
+			t.Errorf("GetByUsername() inside transaction = %+v, %v", got, err)
+		}
+		return txRepos.Users.Debit(ctx, user.ID, 300)
+	})
+	if err != nil {
+		t.Fatalf("WithinTx() error = %v", err)
+	}
+
+	user, err := repos.Users.GetByUsername(ctx, "alice")
+	if err != nil || user == nil || user.Coins != 700 {
+		t.Errorf("GetByUsername() after commit = %+v, %v, want 700 coins", user, err)
+	}
+}
+
+func testRollback(t *testing.T, repos *repository.Repositories, txManager repository.TxManager) {
+	ctx := context.Background()
+	alice := createUser(t, repos, "alice", 1000)
+	errFail := errors.New("fail")
+
+	err := txManager.WithinTx(ctx, func(txRepos *repository.Repositories) error {
+		if err := txRepos.Users.Debit(ctx, alice.ID, 500); err != nil {
+			return err
+		}
+		if err := txRepos.Users.Create(ctx, &models.User{Username: "bob", PasswordHash: "hash", Role: models.RoleUser}); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("WithinTx() error = %v, want %v", err, errFail)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Error("WithinTx() swallowed the panic")
+			}
+		}()
+		_ = txManager.WithinTx(ctx, func(txRepos *repository.Repositories) error {
+			if err := txRepos.Users.Debit(ctx, alice.ID, 500); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+
+	got, _ := repos.Users.GetByID(ctx, alice.ID)
+	if got.Coins != 1000 {
+		t.Errorf("coins after rollback = %d, want 1000", got.Coins)
+	}
+	if bob, _ := repos.Users.GetByUsername(ctx, "bob"); bob != nil {
+		t.Errorf("user created in a rolled back transaction is visible: %+v", bob)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := txManager.WithinTx(cancelled, func(txRepos *repository.Repositories) error {
+		return txRepos.Users.UpdateCoins(ctx, alice.ID, 1)
+	}); err == nil {
+		t.Error("WithinTx() with a cancelled context succeeded")
+	}
+	if got, _ := repos.Users.GetByID(ctx, alice.ID); got.Coins != 1000 {
+		t.Errorf("coins after cancelled transaction = %d, want 1000", got.Coins)
+	}
+}
+
+func testConcurrentDebits(t *testing.T, repos *repository.Repositories, txManager repository.TxManager) {
+	ctx := context.Background()
+	user := createUser(t, repos, "alice", 1000)
+
+	const workers = 20
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := txManager.WithinTx(ctx, func(txRepos *repository.Repositories) error {
+				return txRepos.Users.Debit(ctx, user.ID, 100)
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if !errors.Is(err, repository.ErrInsufficientFunds) {
+				t.Errorf("Debit() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 10 {
+		t.Errorf("%d debits succeeded, want 10", succeeded)
+	}
+	if got, _ := repos.Users.GetByID(ctx, user.ID); got.Coins != 0 {
+		t.Errorf("coins = %d, want 0", got.Coins)
+	}
+}
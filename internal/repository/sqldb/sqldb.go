@@ -0,0 +1,61 @@
+// Package sqldb holds what the database/sql backends have in common: the
+// DBTX abstraction their repositories are written against and a TxManager
+// that runs units of work in a transaction.
+package sqldb
+
+import (
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so a repository can run
+// either on the pool or inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// TxManager implements repository.TxManager for a database/sql backend.
+type TxManager struct {
+	db              *sql.DB
+	newRepositories func(DBTX) *repository.Repositories
+}
+
+// NewTxManager returns a TxManager that binds the backend's repositories to
+// each transaction with newRepositories.
+func NewTxManager(db *sql.DB, newRepositories func(DBTX) *repository.Repositories) *TxManager {
+	return &TxManager{db: db, newRepositories: newRepositories}
+}
+
+// WithinTx runs fn against repositories bound to a single database
+// transaction. The transaction is committed only if fn returns nil; any
+// error, panic or context cancellation rolls it back.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(m.newRepositories(tx)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package sqlite implements the repository interfaces on an embedded SQLite
+// database, so the shop can run as a single binary without Postgres.
+package sqlite
+
+import (
+	"avito-shop/internal/migrate"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// timeFormat matches the text the schema stores in created_at columns, so
+// that bound times compare correctly with stored ones.
+const timeFormat = "2006-01-02 15:04:05.000"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Open opens the database file at path. Transactions take the write lock up
+// front and writers wait for each other instead of failing with SQLITE_BUSY.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to the database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Migrate applies the pending migrations embedded in this package. All of
+// them run in one transaction, which also keeps concurrent processes from
+// migrating the same file at once. Applied migrations are recorded with their
+// checksum in schema_migrations, as the Postgres runner does. It returns the
+// versions it applied, in order, for the caller to report.
+func Migrate(ctx context.Context, db *sql.DB) ([]int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+		)`)
+	if err != nil {
+		return nil, fmt.Errorf("error creating migration table: %w", err)
+	}
+
+	applied, err := appliedChecksums(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+
+		checksum, ok := applied[m.Version]
+		if ok {
+			if checksum != m.Checksum {
+				return nil, fmt.Errorf("%w: %d_%s", migrate.ErrChecksumMismatch, m.Version, m.Name)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return nil, fmt.Errorf("error applying migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum) VALUES (?1, ?2, ?3)`,
+			m.Version, m.Name, m.Checksum); err != nil {
+			return nil, fmt.Errorf("error recording migration %d: %w", m.Version, err)
+		}
+		versions = append(versions, m.Version)
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: version %d", migrate.ErrUnknownVersion, version)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing migrations: %w", err)
+	}
+	return versions, nil
+}
+
+// Check verifies that every embedded migration has been applied unmodified,
+// without applying anything. It is cheap enough for readiness probes.
+func Check(ctx context.Context, db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedChecksums(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		checksum, ok := applied[m.Version]
+		if !ok {
+			return migrate.ErrPending
+		}
+		if checksum != m.Checksum {
+			return fmt.Errorf("%w: %d_%s", migrate.ErrChecksumMismatch, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func loadMigrations() ([]migrate.Migration, error) {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Load(files)
+}
+
+func appliedChecksums(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}) (map[int]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, checksum FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("error reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = checksum
+	}
+
+	return applied, rows.Err()
+}
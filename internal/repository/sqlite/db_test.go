@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"avito-shop/internal/migrate"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	applied, err := Migrate(ctx, db)
+	if err != nil {
+		t.Fatalf("Migrate() on an up-to-date database error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Migrate() on an up-to-date database applied %v, want none", applied)
+	}
+
+	if _, err := db.Exec(`UPDATE schema_migrations SET checksum = 'tampered' WHERE version = 1`); err != nil {
+		t.Fatalf("Failed to tamper with checksum: %v", err)
+	}
+	if _, err := Migrate(ctx, db); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Errorf("Migrate() error = %v, want %v", err, migrate.ErrChecksumMismatch)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if err := Check(ctx, db); err != nil {
+		t.Fatalf("Check() on an up-to-date database error = %v", err)
+	}
+
+	if _, err := db.Exec(`DELETE FROM schema_migrations WHERE version = 1`); err != nil {
+		t.Fatalf("Failed to forget a migration: %v", err)
+	}
+	if err := Check(ctx, db); !errors.Is(err, migrate.ErrPending) {
+		t.Errorf("Check() error = %v, want %v", err, migrate.ErrPending)
+	}
+}
@@ -0,0 +1,26 @@
+package sqlite
+
+import (
+	"avito-shop/internal/repository"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// translateError maps constraint violations to the driver-independent errors
+// of the repository package.
+func translateError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return repository.ErrDuplicate
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return repository.ErrReferenced
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"avito-shop/internal/domain/models"
+	"context"
+	"database/sql"
+)
+
+type MerchandiseRepository struct {
+	db DBTX
+}
+
+func NewMerchandiseRepository(db DBTX) *MerchandiseRepository {
+	return &MerchandiseRepository{db: db}
+}
+
+func (r *MerchandiseRepository) GetByName(ctx context.Context, name string) (*models.Merchandise, error) {
+	merchandise := &models.Merchandise{}
+	query := `
+		SELECT id, name, price, active
+		FROM merchandise
+		WHERE name = ?1`
+
+	err := r.db.QueryRowContext(ctx, query, name).Scan(
+		&merchandise.ID,
+		&merchandise.Name,
+		&merchandise.Price,
+		&merchandise.Active,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return merchandise, nil
+}
+
+func (r *MerchandiseRepository) GetAll(ctx context.Context) ([]*models.Merchandise, error) {
+	query := `
+		SELECT id, name, price, active
+		FROM merchandise
+		ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.Merchandise
+	for rows.Next() {
+		item := &models.Merchandise{}
+		if err := rows.Scan(&item.ID, &item.Name, &item.Price, &item.Active); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (r *MerchandiseRepository) Create(ctx context.Context, item *models.Merchandise) error {
+	query := `
+		INSERT INTO merchandise (name, price, active)
+		VALUES (?1, ?2, ?3)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		item.Name,
+		item.Price,
+		item.Active,
+	).Scan(&item.ID)
+	return translateError(err)
+}
+
+func (r *MerchandiseRepository) Update(ctx context.Context, item *models.Merchandise) error {
+	query := `
+		UPDATE merchandise
+		SET name = ?1, price = ?2, active = ?3
+		WHERE id = ?4`
+
+	result, err := r.db.ExecContext(ctx, query, item.Name, item.Price, item.Active, item.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *MerchandiseRepository) Delete(ctx context.Context, id int64) error {
+	query := `DELETE FROM merchandise WHERE id = ?1`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
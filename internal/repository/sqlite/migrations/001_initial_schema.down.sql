@@ -0,0 +1,8 @@
+DROP TABLE ledger_postings;
+DROP TABLE ledger_entries;
+DROP TABLE ledger_accounts;
+DROP TABLE idempotency_keys;
+DROP TABLE coin_transactions;
+DROP TABLE user_inventory;
+DROP TABLE merchandise;
+DROP TABLE users;
@@ -0,0 +1,82 @@
+-- SQLite counterpart of the Postgres migrations 001-006. Timestamps are
+-- stored as UTC text with millisecond precision so that they sort and
+-- compare correctly as strings.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    coins INTEGER NOT NULL DEFAULT 1000,
+    role TEXT NOT NULL DEFAULT 'user',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE merchandise (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    price INTEGER NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE user_inventory (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id),
+    merchandise_id INTEGER REFERENCES merchandise(id),
+    quantity INTEGER DEFAULT 1,
+    purchased_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE coin_transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_user_id INTEGER REFERENCES users(id),
+    to_user_id INTEGER REFERENCES users(id),
+    amount INTEGER NOT NULL,
+    transaction_type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX coin_transactions_from_user_id_idx ON coin_transactions (from_user_id, id);
+CREATE INDEX coin_transactions_to_user_id_idx ON coin_transactions (to_user_id, id);
+
+CREATE TABLE idempotency_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    idempotency_key TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    response_status INTEGER,
+    response_body BLOB,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    UNIQUE (user_id, idempotency_key)
+);
+
+CREATE TABLE ledger_accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_type TEXT NOT NULL,
+    user_id INTEGER UNIQUE REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CHECK ((account_type = 'USER') = (user_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX ledger_accounts_system_type_idx
+    ON ledger_accounts (account_type)
+    WHERE user_id IS NULL;
+
+CREATE TABLE ledger_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_type TEXT NOT NULL,
+    transaction_id INTEGER REFERENCES coin_transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- SQLite has no deferred triggers, so LedgerRepository.CreateEntry checks
+-- that the postings of an entry balance before inserting them.
+CREATE TABLE ledger_postings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER NOT NULL REFERENCES ledger_entries(id),
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id),
+    amount INTEGER NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX ledger_postings_account_id_idx ON ledger_postings (account_id);
+CREATE INDEX ledger_postings_entry_id_idx ON ledger_postings (entry_id);
+
+INSERT INTO ledger_accounts (account_type) VALUES ('SHOP'), ('ISSUANCE');
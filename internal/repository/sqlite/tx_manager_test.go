@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/repositorytest"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) (*repository.Repositories, repository.TxManager) {
+		db := setupTestDB(t)
+		return NewRepositories(db), NewTxManager(db)
+	})
+}
@@ -0,0 +1,172 @@
+package sqlite
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"database/sql"
+)
+
+type UserRepository struct {
+	db DBTX
+}
+
+func NewUserRepository(db DBTX) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+		INSERT INTO users (username, password_hash, coins, role)
+		VALUES (?1, ?2, ?3, ?4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		user.Username,
+		user.PasswordHash,
+		user.Coins,
+		user.Role,
+	).Scan(&user.ID, &user.CreatedAt)
+	return translateError(err)
+}
+
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, username, password_hash, coins, role FROM users WHERE username = ?1`
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, username, password_hash, coins, role FROM users WHERE id = ?1`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdateCoins(ctx context.Context, userID int64, amount int) error {
+	query := `
+		UPDATE users
+		SET coins = coins + ?1
+		WHERE id = ?2`
+
+	result, err := r.db.ExecContext(ctx, query, amount, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, userID int64, role string) error {
+	query := `
+		UPDATE users
+		SET role = ?1
+		WHERE id = ?2`
+
+	result, err := r.db.ExecContext(ctx, query, role, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *UserRepository) ListByRole(ctx context.Context, role string) ([]*models.User, error) {
+	query := `
+		SELECT id, username, password_hash, coins, role
+		FROM users
+		WHERE role = ?1
+		ORDER BY username`
+
+	rows, err := r.db.QueryContext(ctx, query, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		if err := rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Coins, &user.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) Debit(ctx context.Context, userID int64, amount int) error {
+	query := `
+		UPDATE users
+		SET coins = coins - ?1
+		WHERE id = ?2 AND coins >= ?1`
+
+	result, err := r.db.ExecContext(ctx, query, amount, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows > 0 {
+		return nil
+	}
+
+	var balance int
+	err = r.db.QueryRowContext(ctx, `SELECT coins FROM users WHERE id = ?1`, userID).Scan(&balance)
+	if err != nil {
+		return err
+	}
+
+	return &repository.InsufficientFundsError{
+		UserID:  userID,
+		Balance: balance,
+		Amount:  amount,
+	}
+}
+
+// LockForUpdate is a no-op: transactions begin IMMEDIATE, so a writer holds
+// the database lock from its start and writers never wait on each other
+// mid-transaction.
+func (r *UserRepository) LockForUpdate(ctx context.Context, userIDs ...int64) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package storage opens the backend selected by the database driver setting,
+// so that every command runs on the same store the server does.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"avito-shop/internal/config"
+	"avito-shop/internal/migrate"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/db"
+	"avito-shop/internal/repository/memory"
+	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/repository/sqlite"
+	"avito-shop/migrations"
+)
+
+// Storage is an open backend: its repositories, bound to the database
+// outside of any transaction, and the manager that runs units of work.
+type Storage struct {
+	Repos     *repository.Repositories
+	TxManager repository.TxManager
+
+	ready func(ctx context.Context) error
+	close func() error
+}
+
+// Open connects to the backend cfg selects and, if cfg.AutoMigrate is set,
+// brings its schema up to date, logging the migrations it applies.
+func Open(ctx context.Context, cfg *config.DatabaseConfig, logger *slog.Logger) (*Storage, error) {
+	switch cfg.Driver {
+	case config.DriverMemory:
+		database := memory.NewDB()
+		return &Storage{
+			Repos:     memory.NewRepositories(database),
+			TxManager: memory.NewTxManager(database),
+			ready:     func(context.Context) error { return nil },
+			close:     func() error { return nil },
+		}, nil
+
+	case config.DriverSQLite:
+		database, err := sqlite.Open(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		if cfg.AutoMigrate {
+			applied, err := sqlite.Migrate(ctx, database)
+			if err != nil {
+				database.Close()
+				return nil, fmt.Errorf("failed to apply migrations: %w", err)
+			}
+			logApplied(logger, applied)
+		}
+
+		return &Storage{
+			Repos:     sqlite.NewRepositories(database),
+			TxManager: sqlite.NewTxManager(database),
+			ready: func(ctx context.Context) error {
+				if err := database.PingContext(ctx); err != nil {
+					return err
+				}
+				return sqlite.Check(ctx, database)
+			},
+			close: database.Close,
+		}, nil
+
+	case config.DriverPostgres:
+		database, err := db.NewConnection(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+
+		migrator, err := migrate.New(database, migrations.FS)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+
+		if cfg.AutoMigrate {
+			applied, err := migrator.Up(ctx)
+			if err != nil {
+				database.Close()
+				return nil, fmt.Errorf("failed to apply migrations: %w", err)
+			}
+			logApplied(logger, applied)
+		}
+
+		return &Storage{
+			Repos:     postgres.NewRepositories(database),
+			TxManager: postgres.NewTxManager(database),
+			ready: func(ctx context.Context) error {
+				if err := database.PingContext(ctx); err != nil {
+					return err
+				}
+				return migrator.Check(ctx)
+			},
+			close: database.Close,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+}
+
+// Ready reports whether the database is reachable and fully migrated. It is
+// cheap enough to back a readiness probe.
+func (s *Storage) Ready(ctx context.Context) error {
+	return s.ready(ctx)
+}
+
+// Close releases the connection pool, if the backend has one.
+func (s *Storage) Close() error {
+	return s.close()
+}
+
+// logApplied reports the migrations applied at start-up, if any.
+func logApplied(logger *slog.Logger, versions []int) {
+	if len(versions) > 0 {
+		logger.Info("Applied migrations", "versions", versions)
+	}
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// dummyPasswordHash is compared against when the username is unknown, so
+// that a failed login takes as long as one with a wrong password and does
+// not reveal whether the user exists. It uses bcrypt.DefaultCost, like the
+// hashes Register stores.
+var dummyPasswordHash = []byte("$2a$10$Gt8l9fbcDWrqyH0zxmRd3.vg2pQ49qrRBgXhz6aLBqc2t2XbjsDO2")
+
+// LockoutPolicy throttles password guessing. A username or client IP may
+// fail MaxUserAttempts or MaxIPAttempts times within Window; every further
+// failure locks it out for BaseDelay, doubling with each failure up to
+// MaxDelay. A zero attempt limit disables tracking for that scope.
+type LockoutPolicy struct {
+	MaxUserAttempts int
+	MaxIPAttempts   int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	Window          time.Duration
+}
+
+type AuthServiceConfig struct {
+	TokenSecret     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	Lockout         LockoutPolicy
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+type authService struct {
+	users           repository.UserRepository
+	tokens          repository.TokenRepository
+	attempts        repository.LoginAttemptRepository
+	txManager       repository.TxManager
+	tokenSecret     string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	lockout         LockoutPolicy
+	logger          *slog.Logger
+}
+
+func NewAuthService(
+	users repository.UserRepository,
+	tokens repository.TokenRepository,
+	attempts repository.LoginAttemptRepository,
+	txManager repository.TxManager,
+	cfg AuthServiceConfig,
+) AuthService {
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	return &authService{
+		users:           users,
+		tokens:          tokens,
+		attempts:        attempts,
+		txManager:       txManager,
+		tokenSecret:     cfg.TokenSecret,
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		lockout:         cfg.Lockout,
+		logger:          cfg.Logger,
+	}
+}
+
+func (s *authService) Login(ctx context.Context, username, password, clientIP string) (*models.TokenPair, error) {
+	if username == "" || password == "" {
+		return nil, ErrCredentialsRequired
+	}
+
+	keys := s.lockoutKeys(username, clientIP)
+	if err := s.checkLockout(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user: %w", err)
+	}
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, s.recordFailure(ctx, keys)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, s.recordFailure(ctx, keys)
+	}
+
+	// The IP counter is left alone: an attacker who owns one account must
+	// not be able to clear it between guesses at others.
+	if s.lockout.MaxUserAttempts > 0 {
+		if err := s.attempts.Reset(ctx, models.LoginScopeUsername, username); err != nil {
+			return nil, fmt.Errorf("error resetting failed logins: %w", err)
+		}
+	}
+
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, s.tokens, user, familyID)
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var (
+		pair   *models.TokenPair
+		reused bool
+	)
+	err := s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		token, err := repos.Tokens.GetRefreshToken(ctx, hashToken(refreshToken))
+		if err != nil {
+			return fmt.Errorf("error getting refresh token: %w", err)
+		}
+		if token == nil {
+			return ErrInvalidToken
+		}
+
+		// A rotated token being presented again means it was copied; revoke
+		// the whole session so that neither copy stays usable. The
+		// revocation must commit, so the error is reported after the
+		// transaction.
+		if token.RevokedAt != nil {
+			reused = true
+			return repos.Tokens.RevokeTokenFamily(ctx, token.FamilyID)
+		}
+		if !token.Active(time.Now()) {
+			return ErrInvalidToken
+		}
+
+		if err := repos.Tokens.RevokeRefreshToken(ctx, token.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTokenRevoked
+			}
+			return fmt.Errorf("error revoking refresh token: %w", err)
+		}
+
+		user, err := repos.Users.GetByID(ctx, token.UserID)
+		if err != nil {
+			return fmt.Errorf("error getting user: %w", err)
+		}
+		if user == nil {
+			return ErrInvalidToken
+		}
+
+		pair, err = s.issueTokens(ctx, repos.Tokens, user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		s.logger.WarnContext(ctx, "Revoked refresh token reused; revoking its session")
+		return nil, ErrTokenRevoked
+	}
+
+	return pair, nil
+}
+
+func (s *authService) Logout(ctx context.Context, claims *models.AccessClaims) error {
+	if claims.TokenID != "" {
+		if err := s.tokens.RevokeAccessToken(ctx, claims.TokenID, claims.ExpiresAt); err != nil {
+			return fmt.Errorf("error revoking access token: %w", err)
+		}
+	}
+
+	if claims.FamilyID != "" {
+		if err := s.tokens.RevokeTokenFamily(ctx, claims.FamilyID); err != nil {
+			return fmt.Errorf("error revoking refresh tokens: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *authService) ParseAccessToken(ctx context.Context, tokenString string) (*models.AccessClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.tokenSecret), nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	userID, ok := mapClaims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &models.AccessClaims{
+		UserID: int64(userID),
+		Role:   models.RoleUser,
+	}
+	// Tokens issued before roles were introduced have no role claim.
+	if role, ok := mapClaims["role"].(string); ok {
+		claims.Role = role
+	}
+	if exp, ok := mapClaims["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	claims.FamilyID, _ = mapClaims["sid"].(string)
+
+	// Tokens issued before revocation was introduced have no jti; they
+	// cannot be revoked but expire within a day.
+	if jti, ok := mapClaims["jti"].(string); ok {
+		claims.TokenID = jti
+
+		revoked, err := s.tokens.IsAccessTokenRevoked(ctx, jti)
+		if err != nil {
+			return nil, fmt.Errorf("error checking token revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+func (s *authService) DeleteExpiredTokens(ctx context.Context) error {
+	if err := s.tokens.DeleteExpired(ctx, time.Now()); err != nil {
+		return fmt.Errorf("error deleting expired tokens: %w", err)
+	}
+	return nil
+}
+
+func (s *authService) DeleteStaleLoginAttempts(ctx context.Context) error {
+	if err := s.attempts.DeleteStale(ctx, time.Now().Add(-s.lockout.Window)); err != nil {
+		return fmt.Errorf("error deleting stale login attempts: %w", err)
+	}
+	return nil
+}
+
+type lockoutKey struct {
+	scope       string
+	key         string
+	maxAttempts int
+}
+
+// lockoutKeys lists the counters a login from clientIP as username affects.
+func (s *authService) lockoutKeys(username, clientIP string) []lockoutKey {
+	var keys []lockoutKey
+	if s.lockout.MaxUserAttempts > 0 {
+		keys = append(keys, lockoutKey{models.LoginScopeUsername, username, s.lockout.MaxUserAttempts})
+	}
+	if s.lockout.MaxIPAttempts > 0 && clientIP != "" {
+		keys = append(keys, lockoutKey{models.LoginScopeIP, clientIP, s.lockout.MaxIPAttempts})
+	}
+	return keys
+}
+
+// checkLockout returns ErrLoginLocked, with the longest remaining lockout as
+// RetryAfter, if any of keys is locked.
+func (s *authService) checkLockout(ctx context.Context, keys []lockoutKey) error {
+	now := time.Now()
+	var wait time.Duration
+	for _, k := range keys {
+		attempt, err := s.attempts.Get(ctx, k.scope, k.key)
+		if err != nil {
+			return fmt.Errorf("error checking failed logins: %w", err)
+		}
+		if attempt != nil && attempt.Locked(now) {
+			wait = max(wait, attempt.LockedUntil.Sub(now))
+		}
+	}
+
+	if wait > 0 {
+		locked := *ErrLoginLocked
+		locked.RetryAfter = wait
+		return &locked
+	}
+	return nil
+}
+
+// recordFailure counts a failed login against keys, locking out those over
+// their limit, and returns the error to report for the attempt.
+func (s *authService) recordFailure(ctx context.Context, keys []lockoutKey) error {
+	now := time.Now()
+	for _, k := range keys {
+		failures, err := s.attempts.RecordFailure(ctx, k.scope, k.key, now, now.Add(-s.lockout.Window))
+		if err != nil {
+			return fmt.Errorf("error recording failed login: %w", err)
+		}
+		if failures <= k.maxAttempts {
+			continue
+		}
+
+		delay := s.lockoutDelay(failures - k.maxAttempts)
+		if err := s.attempts.Lock(ctx, k.scope, k.key, now.Add(delay)); err != nil {
+			return fmt.Errorf("error locking out login: %w", err)
+		}
+		s.logger.WarnContext(ctx, "Too many failed logins; locking out",
+			"scope", k.scope, "key", k.key, "failures", failures, "delay", delay)
+	}
+
+	return ErrInvalidCredentials
+}
+
+// lockoutDelay is BaseDelay doubled for every excess failure after the
+// first, capped at MaxDelay.
+func (s *authService) lockoutDelay(excess int) time.Duration {
+	delay := s.lockout.BaseDelay
+	for i := 1; i < excess && delay < s.lockout.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.lockout.MaxDelay)
+}
+
+// issueTokens signs an access token for user and stores a new refresh token
+// in the session identified by familyID.
+func (s *authService) issueTokens(ctx context.Context, tokens repository.TokenRepository, user *models.User, familyID string) (*models.TokenPair, error) {
+	tokenID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"role":    user.Role,
+		"jti":     tokenID,
+		"sid":     familyID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTokenTTL).Unix(),
+	}
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.tokenSecret))
+	if err != nil {
+		return nil, fmt.Errorf("error generating token: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating refresh token: %w", err)
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := tokens.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(s.refreshTokenTTL),
+	}); err != nil {
+		return nil, fmt.Errorf("error storing refresh token: %w", err)
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTokenTTL / time.Second),
+	}, nil
+}
+
+// hashToken is what gets stored for a refresh token. The tokens are random,
+// so a plain SHA-256 is enough to make a leaked table useless.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
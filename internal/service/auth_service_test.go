@@ -0,0 +1,243 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/test/backend"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_Login(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	userService := NewUserService(userRepo, repos.Transactions, txManager, UserServiceConfig{})
+	auth := NewAuthService(userRepo, repos.Tokens, repos.LoginAttempts, txManager, AuthServiceConfig{
+		TokenSecret: "test-secret",
+	})
+
+	ctx := context.Background()
+	if err := userService.Register(ctx, "testuser", "testpass"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := userService.Register(ctx, "testuser", "other"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Register() of existing user error = %v, want %v", err, ErrUserExists)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		wantErr  error
+	}{
+		{name: "Valid credentials", username: "testuser", password: "testpass"},
+		{name: "Wrong password", username: "testuser", password: "wrong", wantErr: ErrInvalidCredentials},
+		{name: "Unknown user", username: "nobody", password: "testpass", wantErr: ErrInvalidCredentials},
+		{name: "Empty password", username: "testuser", password: "", wantErr: ErrCredentialsRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.Login(ctx, tt.username, tt.password, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestDummyPasswordHash checks that logins of unknown users cost as much as
+// those of real ones, whose hashes Register makes with the default cost.
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash)
+	if err != nil {
+		t.Fatalf("bcrypt.Cost() error = %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	userService := NewUserService(userRepo, repos.Transactions, txManager, UserServiceConfig{})
+	auth := NewAuthService(userRepo, repos.Tokens, repos.LoginAttempts, txManager, AuthServiceConfig{
+		TokenSecret:    "test-secret",
+		AccessTokenTTL: time.Minute,
+	})
+
+	ctx := context.Background()
+	if err := userService.Register(ctx, "testuser", "testpass"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	first, err := auth.Login(ctx, "testuser", "testpass", "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if first.ExpiresIn != 60 {
+		t.Errorf("ExpiresIn = %d, want 60", first.ExpiresIn)
+	}
+
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh() returned the same refresh token")
+	}
+	if _, err := auth.ParseAccessToken(ctx, second.AccessToken); err != nil {
+		t.Errorf("ParseAccessToken() of refreshed token error = %v", err)
+	}
+
+	// Presenting the rotated token again revokes the session, including the
+	// token that replaced it.
+	if _, err := auth.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh() with rotated token error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := auth.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh() after reuse error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	if _, err := auth.Refresh(ctx, "unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh() with unknown token error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	userService := NewUserService(userRepo, repos.Transactions, txManager, UserServiceConfig{})
+	auth := NewAuthService(userRepo, repos.Tokens, repos.LoginAttempts, txManager, AuthServiceConfig{
+		TokenSecret: "test-secret",
+	})
+
+	ctx := context.Background()
+	if err := userService.Register(ctx, "testuser", "testpass"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	pair, err := auth.Login(ctx, "testuser", "testpass", "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	other, err := auth.Login(ctx, "testuser", "testpass", "")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	claims, err := auth.ParseAccessToken(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if err := auth.Logout(ctx, claims); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	if _, err := auth.ParseAccessToken(ctx, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("ParseAccessToken() after logout error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := auth.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Refresh() after logout error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	// Other sessions of the same user stay valid.
+	if _, err := auth.ParseAccessToken(ctx, other.AccessToken); err != nil {
+		t.Errorf("ParseAccessToken() of other session error = %v", err)
+	}
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh() of other session error = %v", err)
+	}
+}
+
+func TestAuthService_Lockout(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	attempts := repos.LoginAttempts
+	userService := NewUserService(userRepo, repos.Transactions, txManager, UserServiceConfig{})
+	auth := NewAuthService(userRepo, repos.Tokens, attempts, txManager, AuthServiceConfig{
+		TokenSecret: "test-secret",
+		Lockout: LockoutPolicy{
+			MaxUserAttempts: 2,
+			MaxIPAttempts:   4,
+			BaseDelay:       time.Minute,
+			MaxDelay:        10 * time.Minute,
+			Window:          time.Hour,
+		},
+	})
+
+	ctx := context.Background()
+	for _, username := range []string{"alice", "bob"} {
+		if err := userService.Register(ctx, username, "testpass"); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Login(ctx, "alice", "wrong", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("Login() attempt %d error = %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+	}
+
+	// The third failure locked the username, even from another IP and with
+	// the right password.
+	_, err := auth.Login(ctx, "alice", "testpass", "10.0.0.2")
+	var locked *Error
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("Login() of locked user error = %v, want %v", err, ErrLoginLocked)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want at most %v", locked.RetryAfter, time.Minute)
+	}
+
+	// Two more failures for another user push the IP over its limit.
+	for i := 0; i < 2; i++ {
+		auth.Login(ctx, "bob", "wrong", "10.0.0.1")
+	}
+	if _, err := auth.Login(ctx, "bob", "testpass", "10.0.0.1"); !errors.Is(err, ErrLoginLocked) {
+		t.Errorf("Login() from locked IP error = %v, want %v", err, ErrLoginLocked)
+	}
+	if _, err := auth.Login(ctx, "bob", "testpass", "10.0.0.3"); err != nil {
+		t.Errorf("Login() from other IP error = %v", err)
+	}
+
+	// Once the lockout expires a successful login clears the counter.
+	if err := attempts.Lock(ctx, models.LoginScopeUsername, "alice", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if _, err := auth.Login(ctx, "alice", "testpass", "10.0.0.2"); err != nil {
+		t.Fatalf("Login() after lockout error = %v", err)
+	}
+	if attempt, _ := attempts.Get(ctx, models.LoginScopeUsername, "alice"); attempt != nil {
+		t.Errorf("failed logins after success = %+v, want none", attempt)
+	}
+}
+
+func TestLockoutDelay(t *testing.T) {
+	s := &authService{lockout: LockoutPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}}
+
+	tests := []struct {
+		excess int
+		want   time.Duration
+	}{
+		{excess: 1, want: time.Second},
+		{excess: 2, want: 2 * time.Second},
+		{excess: 4, want: 8 * time.Second},
+		{excess: 5, want: 10 * time.Second},
+		{excess: 100, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := s.lockoutDelay(tt.excess); got != tt.want {
+			t.Errorf("lockoutDelay(%d) = %v, want %v", tt.excess, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kind classifies service errors by what went wrong, so that transports can
+// pick a status code without inspecting messages.
+type Kind int
+
+const (
+	// KindInternal covers failures the client cannot fix, such as database
+	// errors. Every error that is not an *Error is internal.
+	KindInternal Kind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindInsufficientFunds
+	KindUnauthorized
+	KindRateLimited
+	// KindUnprocessable is a well-formed request that cannot be applied as
+	// it stands, such as an idempotency key reused for another request.
+	KindUnprocessable
+)
+
+// Error is a failure the client is allowed to see. Code is a stable
+// machine-readable identifier; Message is meant for humans and may change.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	// RetryAfter, if set, is how long the client should wait before trying
+	// again.
+	RetryAfter time.Duration
+	// Err is the underlying cause, if any. It is not part of Message.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrUserNotFound)
+// holds for copies made by withCause and for formatted validation errors.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrCredentialsRequired = &Error{Kind: KindValidation, Code: "credentials_required", Message: "username and password are required"}
+	ErrInvalidAmount       = &Error{Kind: KindValidation, Code: "invalid_amount", Message: "amount must be positive"}
+	ErrUserNotFound        = &Error{Kind: KindNotFound, Code: "user_not_found", Message: "user not found"}
+	ErrRecipientNotFound   = &Error{Kind: KindNotFound, Code: "recipient_not_found", Message: "recipient not found"}
+	ErrUserExists          = &Error{Kind: KindConflict, Code: "user_exists", Message: "user already exists"}
+	ErrInsufficientFunds   = &Error{Kind: KindInsufficientFunds, Code: "insufficient_funds", Message: "insufficient funds"}
+
+	// ErrInvalidCredentials does not say whether the user exists, so that
+	// login attempts cannot be used to discover usernames.
+	ErrInvalidCredentials = &Error{Kind: KindUnauthorized, Code: "invalid_credentials", Message: "invalid username or password"}
+	ErrInvalidToken       = &Error{Kind: KindUnauthorized, Code: "invalid_token", Message: "invalid or expired token"}
+	ErrTokenRevoked       = &Error{Kind: KindUnauthorized, Code: "token_revoked", Message: "token has been revoked"}
+	ErrLoginLocked        = &Error{Kind: KindRateLimited, Code: "login_locked", Message: "too many failed login attempts, try again later"}
+	// ErrRateLimited is reported by the transport when a client exceeds its
+	// request rate; copies carry the wait in RetryAfter.
+	ErrRateLimited = &Error{Kind: KindRateLimited, Code: "rate_limited", Message: "too many requests, try again later"}
+
+	ErrItemNotFound    = &Error{Kind: KindNotFound, Code: "item_not_found", Message: "item not found"}
+	ErrItemExists      = &Error{Kind: KindConflict, Code: "item_exists", Message: "item already exists"}
+	ErrItemUnavailable = &Error{Kind: KindConflict, Code: "item_unavailable", Message: "item is no longer available"}
+	// ErrItemPurchased is returned when deleting an item somebody has bought;
+	// such items must be deactivated so the purchase history stays intact.
+	ErrItemPurchased = &Error{Kind: KindConflict, Code: "item_purchased", Message: "item has been purchased and can only be deactivated"}
+
+	ErrIdempotencyKeyMismatch   = &Error{Kind: KindUnprocessable, Code: "idempotency_key_mismatch", Message: "idempotency key was already used with a different request"}
+	ErrIdempotencyKeyInProgress = &Error{Kind: KindConflict, Code: "idempotency_key_in_progress", Message: "a request with this idempotency key is still in progress"}
+)
+
+// KindOf reports the kind of err, which is KindInternal unless err wraps an
+// *Error.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// validationError reports invalid input under code with a formatted message.
+func validationError(code, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// withCause returns a copy of sentinel that unwraps to err.
+func withCause(sentinel *Error, err error) *Error {
+	e := *sentinel
+	e.Err = err
+	return &e
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"avito-shop/internal/repository"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	insufficient := withCause(ErrInsufficientFunds, repository.ErrInsufficientFunds)
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKind Kind
+		target   error
+	}{
+		{name: "Sentinel", err: ErrItemNotFound, wantKind: KindNotFound, target: ErrItemNotFound},
+		{name: "Wrapped sentinel", err: fmt.Errorf("buying: %w", ErrUserExists), wantKind: KindConflict, target: ErrUserExists},
+		{name: "Copy with cause", err: insufficient, wantKind: KindInsufficientFunds, target: ErrInsufficientFunds},
+		{name: "Cause of copy", err: insufficient, wantKind: KindInsufficientFunds, target: repository.ErrInsufficientFunds},
+		{name: "Formatted validation error", err: validationError("invalid_filter", "invalid direction %q", "up"), wantKind: KindValidation},
+		{name: "Plain error", err: errors.New("connection refused"), wantKind: KindInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KindOf(tt.err); got != tt.wantKind {
+				t.Errorf("KindOf() = %v, want %v", got, tt.wantKind)
+			}
+			if tt.target != nil && !errors.Is(tt.err, tt.target) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tt.err, tt.target)
+			}
+		})
+	}
+
+	if errors.Is(ErrItemNotFound, ErrUserNotFound) {
+		t.Error("errors with different codes must not match")
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultIdempotencyTTL is how long keys are kept when no TTL is configured.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+type idempotencyService struct {
+	keys repository.IdempotencyRepository
+	ttl  time.Duration
+}
+
+// NewIdempotencyService remembers keys for ttl, after which Begin treats them
+// as unused. A non-positive ttl selects the default.
+func NewIdempotencyService(keys repository.IdempotencyRepository, ttl time.Duration) IdempotencyService {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	return &idempotencyService{
+		keys: keys,
+		ttl:  ttl,
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, userID int64, key, requestHash string) (*models.IdempotencyKey, bool, error) {
+	if userID == 0 {
+		return nil, false, fmt.Errorf("invalid user ID")
+	}
+
+	if key == "" {
+		return nil, false, fmt.Errorf("idempotency key is required")
+	}
+
+	record := &models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+	}
+
+	reserved, err := s.keys.Reserve(ctx, record)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reserving idempotency key: %w", err)
+	}
+	if reserved {
+		return record, false, nil
+	}
+
+	existing, err := s.keys.Get(ctx, userID, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting idempotency key: %w", err)
+	}
+
+	// An expired key is forgotten, including one whose request never
+	// finished because the server crashed, and the key is reserved afresh.
+	if existing != nil && time.Since(existing.CreatedAt) > s.ttl {
+		if err := s.keys.Delete(ctx, existing.ID); err != nil {
+			return nil, false, fmt.Errorf("error deleting expired idempotency key: %w", err)
+		}
+		reserved, err := s.keys.Reserve(ctx, record)
+		if err != nil {
+			return nil, false, fmt.Errorf("error reserving idempotency key: %w", err)
+		}
+		if reserved {
+			return record, false, nil
+		}
+		// A concurrent request reserved the key first.
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+
+	if existing == nil {
+		// The reservation was released between our insert and select; the
+		// client can simply retry.
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyKeyMismatch
+	}
+
+	if !existing.Completed() {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+
+	return existing, true, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, id int64, status int, body []byte) error {
+	if err := s.keys.SaveResponse(ctx, id, status, body); err != nil {
+		return fmt.Errorf("error saving idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) Release(ctx context.Context, id int64) error {
+	if err := s.keys.Delete(ctx, id); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) DeleteExpiredKeys(ctx context.Context) error {
+	if err := s.keys.DeleteExpired(ctx, time.Now().Add(-s.ttl)); err != nil {
+		return fmt.Errorf("error deleting expired idempotency keys: %w", err)
+	}
+	return nil
+}
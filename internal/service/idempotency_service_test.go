@@ -0,0 +1,63 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/test/backend"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyService_Expiry(t *testing.T) {
+	repos, _ := backend.New(t)
+	ctx := context.Background()
+
+	user := &models.User{Username: "alice", PasswordHash: "hash", Coins: 1000, Role: models.RoleUser}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	const ttl = 100 * time.Millisecond
+	service := NewIdempotencyService(repos.Idempotency, ttl)
+
+	// A reservation that is never completed, as after a crash.
+	abandoned, _, err := service.Begin(ctx, user.ID, "key-1", "hash")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if _, _, err := service.Begin(ctx, user.ID, "key-1", "hash"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Fatalf("Begin() of a key in progress error = %v, want %v", err, ErrIdempotencyKeyInProgress)
+	}
+
+	time.Sleep(2 * ttl)
+
+	record, replay, err := service.Begin(ctx, user.ID, "key-1", "other-hash")
+	if err != nil || replay {
+		t.Fatalf("Begin() of an expired key = %v, %v, want a fresh reservation", replay, err)
+	}
+	if record.ID == abandoned.ID {
+		t.Error("Begin() of an expired key returned the old reservation")
+	}
+
+	if _, _, err := service.Begin(ctx, user.ID, "key-2", "hash"); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	time.Sleep(2 * ttl)
+	if _, _, err := service.Begin(ctx, user.ID, "key-3", "hash"); err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+
+	if err := service.DeleteExpiredKeys(ctx); err != nil {
+		t.Fatalf("DeleteExpiredKeys() error = %v", err)
+	}
+	for key, want := range map[string]bool{"key-1": false, "key-2": false, "key-3": true} {
+		record, err := repos.Idempotency.Get(ctx, user.ID, key)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", key, err)
+		}
+		if got := record != nil; got != want {
+			t.Errorf("%s kept after DeleteExpiredKeys = %v, want %v", key, got, want)
+		}
+	}
+}
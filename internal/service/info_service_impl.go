@@ -29,10 +29,10 @@ func (s *infoService) GetUserInfo(ctx context.Context, userID int64) (*models.In
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 
-	transactions, err := s.transactions.GetUserTransactions(ctx, userID)
+	history, err := s.transactions.GetUserHistory(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting transactions: %w", err)
 	}
@@ -40,39 +40,23 @@ func (s *infoService) GetUserInfo(ctx context.Context, userID int64) (*models.In
 	var received []models.CoinReceived
 	var sent []models.CoinSent
 
-	for _, t := range transactions {
+	for _, t := range history {
 		if t.ToUserID != nil && *t.ToUserID == userID {
-			fromUser, err := s.users.GetByID(ctx, t.FromUserID)
-			if err != nil {
-				return nil, fmt.Errorf("error getting sender info: %w", err)
-			}
-			senderName := "Unknown"
-			if fromUser != nil {
-				senderName = fromUser.Username
-			}
 			received = append(received, models.CoinReceived{
-				FromUser: senderName,
+				FromUser: usernameOrUnknown(t.FromUsername),
 				Amount:   t.Amount,
 			})
-		} else {
-			var toUser string
-			if t.TransactionType == models.TransactionTypePurchase {
-				toUser = "SHOP"
-			} else {
-				recipient, err := s.users.GetByID(ctx, *t.ToUserID)
-				if err != nil {
-					return nil, fmt.Errorf("error getting recipient info: %w", err)
-				}
-				toUser = "Unknown"
-				if recipient != nil {
-					toUser = recipient.Username
-				}
-			}
-			sent = append(sent, models.CoinSent{
-				ToUser: toUser,
-				Amount: t.Amount,
-			})
+			continue
+		}
+
+		toUser := models.ShopAccountName
+		if t.TransactionType != models.TransactionTypePurchase {
+			toUser = usernameOrUnknown(t.ToUsername)
 		}
+		sent = append(sent, models.CoinSent{
+			ToUser: toUser,
+			Amount: t.Amount,
+		})
 	}
 
 	inventory, err := s.inventory.GetUserItems(ctx, userID)
@@ -89,3 +73,10 @@ func (s *infoService) GetUserInfo(ctx context.Context, userID int64) (*models.In
 		Inventory: inventory,
 	}, nil
 }
+
+func usernameOrUnknown(username string) string {
+	if username == "" {
+		return "Unknown"
+	}
+	return username
+}
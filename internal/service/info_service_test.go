@@ -4,9 +4,14 @@ import (
 	"avito-shop/internal/domain/models"
 	"avito-shop/internal/repository"
 	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/repository/sqlite"
 	"avito-shop/internal/test"
+	"avito-shop/internal/test/backend"
 	"context"
 	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 )
 
@@ -16,8 +21,7 @@ const (
 )
 
 type testSetup struct {
-	db           *sql.DB
-	cleanup      func()
+	repos        *repository.Repositories
 	infoService  InfoService
 	userService  UserService
 	merchService MerchandiseService
@@ -26,26 +30,24 @@ type testSetup struct {
 
 func TestInfoService_GetUserInfo(t *testing.T) {
 	ts := setupInfoServiceTest(t)
-	defer ts.cleanup()
 
 	user := createTestUser(t, ts)
 	runInfoServiceTests(t, ts, user)
 }
 
 func setupInfoServiceTest(t *testing.T) *testSetup {
-	db, cleanup := test.SetupTestDB(t)
-	userRepo := postgres.NewUserRepository(db)
-	merchRepo := postgres.NewMerchandiseRepository(db)
-	invRepo := postgres.NewUserInventoryRepository(db)
-	transRepo := postgres.NewTransactionRepository(db)
+	repos, txManager := backend.New(t)
+	userRepo := repos.Users
+	merchRepo := repos.Merchandise
+	invRepo := repos.Inventory
+	transRepo := repos.Transactions
 
 	infoService := NewInfoService(userRepo, merchRepo, transRepo, invRepo)
-	userService := NewUserService(userRepo, transRepo, "test-secret")
-	merchService := NewMerchandiseService(userRepo, merchRepo, invRepo, transRepo)
+	userService := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+	merchService := NewMerchandiseService(userRepo, merchRepo, invRepo, transRepo, txManager)
 
 	return &testSetup{
-		db:           db,
-		cleanup:      cleanup,
+		repos:        repos,
 		infoService:  infoService,
 		userService:  userService,
 		merchService: merchService,
@@ -74,10 +76,7 @@ func createTestUser(t *testing.T, ts *testSetup) *models.User {
 		t.Fatalf("Failed to get test user: %v", err)
 	}
 
-	_, err = ts.db.Exec(`
-		INSERT INTO merchandise (name, price) 
-		VALUES ('test-item', 500)
-	`)
+	err = ts.repos.Merchandise.Create(ctx, &models.Merchandise{Name: "test-item", Price: 500, Active: true})
 	if err != nil {
 		t.Fatalf("Failed to insert test merchandise: %v", err)
 	}
@@ -173,3 +172,164 @@ func runInfoServiceTests(t *testing.T, ts *testSetup, user *models.User) {
 		})
 	}
 }
+
+// countingDB counts the statements issued through it so that tests and
+// benchmarks can show the number of queries per call does not grow with
+// history size.
+type countingDB struct {
+	*sql.DB
+	queries atomic.Int64
+}
+
+func (c *countingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries.Add(1)
+	return c.DB.ExecContext(ctx, query, args...)
+}
+
+func (c *countingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.queries.Add(1)
+	return c.DB.QueryContext(ctx, query, args...)
+}
+
+func (c *countingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	c.queries.Add(1)
+	return c.DB.QueryRowContext(ctx, query, args...)
+}
+
+// TestInfoService_GetUserInfo_QueryCount guards against resolving the
+// counterparties of the history one query at a time: a user with 1000
+// transactions must cost as many statements as one with 10.
+func TestInfoService_GetUserInfo_QueryCount(t *testing.T) {
+	ctx := context.Background()
+	queries := make(map[int]int64)
+
+	for _, size := range []int{10, 1000} {
+		db, userID := seedSQLiteHistory(t, size)
+		counter := &countingDB{DB: db}
+		infoService := NewInfoService(
+			sqlite.NewUserRepository(counter),
+			sqlite.NewMerchandiseRepository(counter),
+			sqlite.NewTransactionRepository(counter),
+			sqlite.NewUserInventoryRepository(counter),
+		)
+
+		info, err := infoService.GetUserInfo(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserInfo() error = %v", err)
+		}
+		if got := len(info.CoinHistory.Sent) + len(info.CoinHistory.Received); got != size {
+			t.Fatalf("History length = %d, want %d", got, size)
+		}
+		queries[size] = counter.queries.Load()
+	}
+
+	if queries[10] != queries[1000] {
+		t.Errorf("GetUserInfo() issued %d queries for 10 transactions and %d for 1000, want the same",
+			queries[10], queries[1000])
+	}
+}
+
+// seedSQLiteHistory creates a SQLite database with one user who exchanged
+// coins with size distinct counterparties, half sent and half received.
+func seedSQLiteHistory(t *testing.T, size int) (*sql.DB, int64) {
+	t.Helper()
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := sqlite.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
+	}
+
+	var userID int64
+	err = db.QueryRow(`
+		INSERT INTO users (username, password_hash, coins)
+		VALUES ('bench', 'x', 1000)
+		RETURNING id`).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	_, err = db.Exec(`
+		WITH RECURSIVE g(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM g WHERE n < ?1)
+		INSERT INTO users (username, password_hash, coins)
+		SELECT 'peer' || n, 'x', 1000 FROM g`, size)
+	if err != nil {
+		t.Fatalf("Failed to create counterparties: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO coin_transactions (from_user_id, to_user_id, amount, transaction_type)
+		SELECT
+			CASE WHEN id % 2 = 0 THEN ?1 ELSE id END,
+			CASE WHEN id % 2 = 0 THEN id ELSE ?1 END,
+			1, 'TRANSFER'
+		FROM users WHERE id <> ?1`, userID)
+	if err != nil {
+		t.Fatalf("Failed to seed history: %v", err)
+	}
+
+	return db, userID
+}
+
+func BenchmarkInfoService_GetUserInfo(b *testing.B) {
+	db, cleanup := test.SetupTestDB(b)
+	defer cleanup()
+
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("history=%d", size), func(b *testing.B) {
+			test.ClearTestDB(b, db)
+
+			// Seed directly: one user who exchanged coins with size distinct
+			// counterparties, half sent and half received.
+			var userID int64
+			err := db.QueryRow(`
+				INSERT INTO users (username, password_hash, coins)
+				VALUES ('bench', 'x', 1000)
+				RETURNING id`).Scan(&userID)
+			if err != nil {
+				b.Fatalf("Failed to create user: %v", err)
+			}
+
+			_, err = db.Exec(`
+				WITH others AS (
+					INSERT INTO users (username, password_hash, coins)
+					SELECT 'peer' || g, 'x', 1000 FROM generate_series(1, $2::int) g
+					RETURNING id
+				)
+				INSERT INTO coin_transactions (from_user_id, to_user_id, amount, transaction_type)
+				SELECT
+					CASE WHEN id % 2 = 0 THEN $1::int ELSE id END,
+					CASE WHEN id % 2 = 0 THEN id ELSE $1::int END,
+					1, 'TRANSFER'
+				FROM others`, userID, size)
+			if err != nil {
+				b.Fatalf("Failed to seed history: %v", err)
+			}
+
+			counter := &countingDB{DB: db}
+			infoService := NewInfoService(
+				postgres.NewUserRepository(counter),
+				postgres.NewMerchandiseRepository(counter),
+				postgres.NewTransactionRepository(counter),
+				postgres.NewUserInventoryRepository(counter),
+			)
+
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				info, err := infoService.GetUserInfo(ctx, userID)
+				if err != nil {
+					b.Fatalf("GetUserInfo() error = %v", err)
+				}
+				if got := len(info.CoinHistory.Sent) + len(info.CoinHistory.Received); got != size {
+					b.Fatalf("History length = %d, want %d", got, size)
+				}
+			}
+			b.ReportMetric(float64(counter.queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrUnbalancedEntry = errors.New("journal entry postings do not sum to zero")
+
+type ledgerService struct {
+	ledger repository.LedgerRepository
+}
+
+func NewLedgerService(ledger repository.LedgerRepository) LedgerService {
+	return &ledgerService{
+		ledger: ledger,
+	}
+}
+
+func (s *ledgerService) GetUserBalance(ctx context.Context, userID int64) (int, error) {
+	account, err := s.ledger.GetUserAccount(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting ledger account: %w", err)
+	}
+	if account == nil {
+		return 0, fmt.Errorf("ledger account not found")
+	}
+
+	balance, err := s.ledger.GetBalance(ctx, account.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting ledger balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+func (s *ledgerService) FindBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error) {
+	mismatches, err := s.ledger.GetBalanceMismatches(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing balances with ledger: %w", err)
+	}
+	return mismatches, nil
+}
+
+// openUserAccount creates the ledger account of a new user and funds it with
+// the starting grant from the issuance account.
+func openUserAccount(ctx context.Context, ledger repository.LedgerRepository, userID int64, grant int) error {
+	account := &models.LedgerAccount{
+		AccountType: models.AccountTypeUser,
+		UserID:      &userID,
+	}
+	if err := ledger.CreateAccount(ctx, account); err != nil {
+		return fmt.Errorf("error creating ledger account: %w", err)
+	}
+
+	if grant == 0 {
+		return nil
+	}
+
+	issuance, err := systemAccount(ctx, ledger, models.AccountTypeIssuance)
+	if err != nil {
+		return err
+	}
+
+	return postEntry(ctx, ledger, &models.JournalEntry{
+		EntryType: models.EntryTypeGrant,
+		Postings: []models.Posting{
+			{AccountID: issuance.ID, Amount: -grant},
+			{AccountID: account.ID, Amount: grant},
+		},
+	})
+}
+
+func postTransfer(ctx context.Context, ledger repository.LedgerRepository, transaction *models.Transaction) error {
+	from, err := userAccount(ctx, ledger, transaction.FromUserID)
+	if err != nil {
+		return err
+	}
+
+	to, err := userAccount(ctx, ledger, *transaction.ToUserID)
+	if err != nil {
+		return err
+	}
+
+	return postEntry(ctx, ledger, &models.JournalEntry{
+		EntryType:     models.EntryTypeTransfer,
+		TransactionID: &transaction.ID,
+		Postings: []models.Posting{
+			{AccountID: from.ID, Amount: -transaction.Amount},
+			{AccountID: to.ID, Amount: transaction.Amount},
+		},
+	})
+}
+
+func postPurchase(ctx context.Context, ledger repository.LedgerRepository, transaction *models.Transaction) error {
+	buyer, err := userAccount(ctx, ledger, transaction.FromUserID)
+	if err != nil {
+		return err
+	}
+
+	shop, err := systemAccount(ctx, ledger, models.AccountTypeShop)
+	if err != nil {
+		return err
+	}
+
+	return postEntry(ctx, ledger, &models.JournalEntry{
+		EntryType:     models.EntryTypePurchase,
+		TransactionID: &transaction.ID,
+		Postings: []models.Posting{
+			{AccountID: buyer.ID, Amount: -transaction.Amount},
+			{AccountID: shop.ID, Amount: transaction.Amount},
+		},
+	})
+}
+
+// postAdjustment moves amount between the issuance account and a user's
+// account to record a manual balance correction.
+func postAdjustment(ctx context.Context, ledger repository.LedgerRepository, userID int64, amount int) error {
+	account, err := userAccount(ctx, ledger, userID)
+	if err != nil {
+		return err
+	}
+
+	issuance, err := systemAccount(ctx, ledger, models.AccountTypeIssuance)
+	if err != nil {
+		return err
+	}
+
+	return postEntry(ctx, ledger, &models.JournalEntry{
+		EntryType: models.EntryTypeAdjustment,
+		Postings: []models.Posting{
+			{AccountID: issuance.ID, Amount: -amount},
+			{AccountID: account.ID, Amount: amount},
+		},
+	})
+}
+
+func postEntry(ctx context.Context, ledger repository.LedgerRepository, entry *models.JournalEntry) error {
+	if len(entry.Postings) < 2 {
+		return fmt.Errorf("journal entry needs at least two postings")
+	}
+
+	sum := 0
+	for _, posting := range entry.Postings {
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalancedEntry
+	}
+
+	if err := ledger.CreateEntry(ctx, entry); err != nil {
+		return fmt.Errorf("error recording journal entry: %w", err)
+	}
+	return nil
+}
+
+func userAccount(ctx context.Context, ledger repository.LedgerRepository, userID int64) (*models.LedgerAccount, error) {
+	account, err := ledger.GetUserAccount(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ledger account: %w", err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("ledger account not found for user %d", userID)
+	}
+	return account, nil
+}
+
+func systemAccount(ctx context.Context, ledger repository.LedgerRepository, accountType string) (*models.LedgerAccount, error) {
+	account, err := ledger.GetSystemAccount(ctx, accountType)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s account: %w", accountType, err)
+	}
+	if account == nil {
+		return nil, fmt.Errorf("%s account not found", accountType)
+	}
+	return account, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/test/backend"
+	"context"
+	"testing"
+)
+
+func TestLedgerService_TracksEveryMovement(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	merchRepo := repos.Merchandise
+	invRepo := repos.Inventory
+	transRepo := repos.Transactions
+	ledgerRepo := repos.Ledger
+
+	userService := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+	merchService := NewMerchandiseService(userRepo, merchRepo, invRepo, transRepo, txManager)
+	ledgerService := NewLedgerService(ledgerRepo)
+
+	ctx := context.Background()
+
+	for _, name := range []string{"sender", "recipient"} {
+		if err := userService.Register(ctx, name, "testpass"); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sender, err := userRepo.GetByUsername(ctx, "sender")
+	if err != nil || sender == nil {
+		t.Fatal("Failed to get sender")
+	}
+	recipient, err := userRepo.GetByUsername(ctx, "recipient")
+	if err != nil || recipient == nil {
+		t.Fatal("Failed to get recipient")
+	}
+
+	if err := repos.Merchandise.Create(ctx, &models.Merchandise{Name: "test-item", Price: 300, Active: true}); err != nil {
+		t.Fatalf("Failed to insert test merchandise: %v", err)
+	}
+
+	if err := userService.TransferCoins(ctx, sender.ID, "recipient", 200); err != nil {
+		t.Fatalf("Failed to transfer coins: %v", err)
+	}
+	if err := merchService.BuyItem(ctx, sender.ID, "test-item"); err != nil {
+		t.Fatalf("Failed to buy item: %v", err)
+	}
+
+	balances := map[int64]int{sender.ID: 500, recipient.ID: 1200}
+	for userID, want := range balances {
+		got, err := ledgerService.GetUserBalance(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserBalance() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Ledger balance of user %d = %d, want %d", userID, got, want)
+		}
+	}
+
+	shop, err := ledgerRepo.GetSystemAccount(ctx, models.AccountTypeShop)
+	if err != nil || shop == nil {
+		t.Fatalf("Failed to get shop account: %v", err)
+	}
+	shopBalance, err := ledgerRepo.GetBalance(ctx, shop.ID)
+	if err != nil {
+		t.Fatalf("Failed to get shop balance: %v", err)
+	}
+	if shopBalance != 300 {
+		t.Errorf("Shop balance = %d, want %d", shopBalance, 300)
+	}
+
+	mismatches, err := ledgerService.FindBalanceMismatches(ctx)
+	if err != nil {
+		t.Fatalf("FindBalanceMismatches() error = %v", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("FindBalanceMismatches() = %d mismatches, want none", len(mismatches))
+	}
+
+	if err := repos.Users.UpdateCoins(ctx, sender.ID, 50); err != nil {
+		t.Fatalf("Failed to tamper with balance: %v", err)
+	}
+
+	mismatches, err = ledgerService.FindBalanceMismatches(ctx)
+	if err != nil {
+		t.Fatalf("FindBalanceMismatches() error = %v", err)
+	}
+	if len(mismatches) != 1 {
+		t.Fatalf("FindBalanceMismatches() = %d mismatches, want 1", len(mismatches))
+	}
+	if m := mismatches[0]; m.UserID != sender.ID || m.StoredBalance != 550 || m.ExpectedBalance != 500 {
+		t.Errorf("Mismatch = %+v, want user %d stored 550 expected 500", *m, sender.ID)
+	}
+}
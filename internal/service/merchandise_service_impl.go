@@ -2,16 +2,23 @@ package service
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"avito-shop/internal/repository"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 )
 
+const maxItemNameLength = 255
+
 type merchandiseService struct {
 	users        repository.UserRepository
 	merchandise  repository.MerchandiseRepository
 	inventory    repository.UserInventoryRepository
 	transactions repository.TransactionRepository
+	txManager    repository.TxManager
 }
 
 func NewMerchandiseService(
@@ -19,16 +26,33 @@ func NewMerchandiseService(
 	merchandise repository.MerchandiseRepository,
 	inventory repository.UserInventoryRepository,
 	transactions repository.TransactionRepository,
+	txManager repository.TxManager,
 ) MerchandiseService {
 	return &merchandiseService{
 		users:        users,
 		merchandise:  merchandise,
 		inventory:    inventory,
 		transactions: transactions,
+		txManager:    txManager,
 	}
 }
 
 func (s *merchandiseService) GetAll(ctx context.Context) ([]*models.Merchandise, error) {
+	items, err := s.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := items[:0]
+	for _, item := range items {
+		if item.Active {
+			active = append(active, item)
+		}
+	}
+	return active, nil
+}
+
+func (s *merchandiseService) ListAll(ctx context.Context) ([]*models.Merchandise, error) {
 	items, err := s.merchandise.GetAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error getting merchandise: %w", err)
@@ -36,55 +60,183 @@ func (s *merchandiseService) GetAll(ctx context.Context) ([]*models.Merchandise,
 	return items, nil
 }
 
-func (s *merchandiseService) BuyItem(ctx context.Context, userID int64, itemName string) error {
-	if userID == 0 {
-		return fmt.Errorf("invalid user ID")
+func (s *merchandiseService) Create(ctx context.Context, name string, price int) (*models.Merchandise, error) {
+	if err := validateItemName(name); err != nil {
+		return nil, err
+	}
+	if err := validateItemPrice(price); err != nil {
+		return nil, err
 	}
 
-	if itemName == "" {
-		return fmt.Errorf("item name is required")
+	item := &models.Merchandise{
+		Name:   name,
+		Price:  price,
+		Active: true,
+	}
+
+	if err := s.merchandise.Create(ctx, item); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrItemExists
+		}
+		return nil, fmt.Errorf("error creating item: %w", err)
+	}
+
+	return item, nil
+}
+
+func (s *merchandiseService) Update(ctx context.Context, name string, update models.MerchandiseUpdate) (*models.Merchandise, error) {
+	if update.Name != nil {
+		if err := validateItemName(*update.Name); err != nil {
+			return nil, err
+		}
+	}
+	if update.Price != nil {
+		if err := validateItemPrice(*update.Price); err != nil {
+			return nil, err
+		}
 	}
 
-	item, err := s.merchandise.GetByName(ctx, itemName)
+	item, err := s.GetByName(ctx, name)
 	if err != nil {
-		return fmt.Errorf("error getting item: %w", err)
+		return nil, err
 	}
-	if item == nil {
-		return fmt.Errorf("item not found")
+
+	if update.Name != nil {
+		item.Name = *update.Name
+	}
+	if update.Price != nil {
+		item.Price = *update.Price
+	}
+	if update.Active != nil {
+		item.Active = *update.Active
+	}
+
+	if err := s.merchandise.Update(ctx, item); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrItemExists
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrItemNotFound
+		}
+		return nil, fmt.Errorf("error updating item: %w", err)
 	}
 
-	user, err := s.users.GetByID(ctx, userID)
+	return item, nil
+}
+
+func (s *merchandiseService) Delete(ctx context.Context, name string) error {
+	item, err := s.GetByName(ctx, name)
 	if err != nil {
-		return fmt.Errorf("error getting user: %w", err)
+		return err
 	}
-	if user == nil {
-		return fmt.Errorf("user not found")
+
+	if err := s.merchandise.Delete(ctx, item.ID); err != nil {
+		if errors.Is(err, repository.ErrReferenced) {
+			return ErrItemPurchased
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		return fmt.Errorf("error deleting item: %w", err)
 	}
 
-	if user.Coins < item.Price {
-		return fmt.Errorf("insufficient funds: have %d, need %d", user.Coins, item.Price)
+	return nil
+}
+
+func validateItemName(name string) error {
+	if strings.TrimSpace(name) != name || name == "" {
+		return validationError("invalid_item_name", "item name must be non-empty without surrounding spaces")
+	}
+	if len(name) > maxItemNameLength {
+		return validationError("invalid_item_name", "item name must be at most %d bytes", maxItemNameLength)
+	}
+	if strings.Contains(name, "/") {
+		return validationError("invalid_item_name", "item name must not contain '/'")
 	}
+	return nil
+}
 
-	transaction := &models.Transaction{
-		FromUserID:      userID,
-		ToUserID:        nil,
-		Amount:          item.Price,
-		TransactionType: models.TransactionTypePurchase,
+func validateItemPrice(price int) error {
+	if price <= 0 {
+		return validationError("invalid_price", "price must be positive")
 	}
+	return nil
+}
 
-	if err := s.users.UpdateCoins(ctx, userID, -item.Price); err != nil {
-		return fmt.Errorf("error updating user balance: %w", err)
+func (s *merchandiseService) GetByName(ctx context.Context, name string) (*models.Merchandise, error) {
+	if name == "" {
+		return nil, validationError("invalid_item_name", "item name is required")
 	}
 
-	if err := s.transactions.Create(ctx, transaction); err != nil {
-		_ = s.users.UpdateCoins(ctx, userID, item.Price)
-		return fmt.Errorf("error recording transaction: %w", err)
+	item, err := s.merchandise.GetByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting item: %w", err)
+	}
+	if item == nil {
+		return nil, ErrItemNotFound
 	}
 
-	if err := s.inventory.AddItem(ctx, userID, item.ID); err != nil {
-		_ = s.users.UpdateCoins(ctx, userID, item.Price)
-		return fmt.Errorf("error updating inventory: %w", err)
+	return item, nil
+}
+
+func (s *merchandiseService) BuyItem(ctx context.Context, userID int64, itemName string) error {
+	if userID == 0 {
+		return validationError("invalid_user_id", "invalid user ID")
+	}
+
+	if itemName == "" {
+		return validationError("invalid_item_name", "item name is required")
+	}
+
+	var price int
+	err := s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		item, err := repos.Merchandise.GetByName(ctx, itemName)
+		if err != nil {
+			return fmt.Errorf("error getting item: %w", err)
+		}
+		if item == nil {
+			return ErrItemNotFound
+		}
+		if !item.Active {
+			return ErrItemUnavailable
+		}
+		price = item.Price
+
+		transaction := &models.Transaction{
+			FromUserID:      userID,
+			ToUserID:        nil,
+			Amount:          item.Price,
+			TransactionType: models.TransactionTypePurchase,
+		}
+
+		if err := repos.Users.Debit(ctx, userID, item.Price); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			if errors.Is(err, repository.ErrInsufficientFunds) {
+				return withCause(ErrInsufficientFunds, err)
+			}
+			return fmt.Errorf("error updating user balance: %w", err)
+		}
+
+		if err := repos.Transactions.Create(ctx, transaction); err != nil {
+			return fmt.Errorf("error recording transaction: %w", err)
+		}
+
+		if err := postPurchase(ctx, repos.Ledger, transaction); err != nil {
+			return err
+		}
+
+		if err := repos.Inventory.AddItem(ctx, userID, item.ID); err != nil {
+			return fmt.Errorf("error updating inventory: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	metrics.RecordCoins(metrics.OperationPurchase, price)
 	return nil
 }
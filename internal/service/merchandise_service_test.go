@@ -1,33 +1,33 @@
 package service
 
 import (
-	"avito-shop/internal/repository/postgres"
-	"avito-shop/internal/test"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/test/backend"
 	"context"
 	"testing"
 )
 
 func TestMerchandiseService_BuyItem(t *testing.T) {
-	db, cleanup := test.SetupTestDB(t)
-	defer cleanup()
+	repos, txManager := backend.New(t)
 
-	userRepo := postgres.NewUserRepository(db)
-	merchRepo := postgres.NewMerchandiseRepository(db)
-	invRepo := postgres.NewUserInventoryRepository(db)
-	transRepo := postgres.NewTransactionRepository(db)
+	userRepo := repos.Users
+	merchRepo := repos.Merchandise
+	invRepo := repos.Inventory
+	transRepo := repos.Transactions
 
 	service := NewMerchandiseService(
 		userRepo,
 		merchRepo,
 		invRepo,
 		transRepo,
+		txManager,
 	)
 
 	ctx := context.Background()
 	testUser := "testuser"
 	testPass := "testpass"
 
-	userService := NewUserService(userRepo, transRepo, "test-secret")
+	userService := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
 	err := userService.Register(ctx, testUser, testPass)
 	if err != nil {
 		t.Fatalf("Failed to create test user: %v", err)
@@ -38,10 +38,7 @@ func TestMerchandiseService_BuyItem(t *testing.T) {
 		t.Fatalf("Failed to get test user: %v", err)
 	}
 
-	_, err = db.Exec(`
-		INSERT INTO merchandise (name, price) 
-		VALUES ('test-item', 100)
-	`)
+	err = repos.Merchandise.Create(ctx, &models.Merchandise{Name: "test-item", Price: 100, Active: true})
 	if err != nil {
 		t.Fatalf("Failed to insert test merchandise: %v", err)
 	}
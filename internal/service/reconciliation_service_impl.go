@@ -0,0 +1,95 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+type reconciliationService struct {
+	transactions repository.TransactionRepository
+	ledger       repository.LedgerRepository
+	txManager    repository.TxManager
+}
+
+func NewReconciliationService(
+	transactions repository.TransactionRepository,
+	ledger repository.LedgerRepository,
+	txManager repository.TxManager,
+) ReconciliationService {
+	return &reconciliationService{
+		transactions: transactions,
+		ledger:       ledger,
+		txManager:    txManager,
+	}
+}
+
+func (s *reconciliationService) Reconcile(ctx context.Context, fix bool) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{StartedAt: time.Now()}
+
+	historyMismatches, err := s.transactions.GetHistoryMismatches(ctx, initialCoins)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing balances with history: %w", err)
+	}
+	report.HistoryMismatches = historyMismatches
+
+	if fix {
+		for _, mismatch := range historyMismatches {
+			if err := s.correct(ctx, mismatch); err != nil {
+				return nil, fmt.Errorf("error correcting balance of user %d: %w", mismatch.UserID, err)
+			}
+			report.Corrected++
+		}
+	}
+
+	ledgerMismatches, err := s.ledger.GetBalanceMismatches(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing balances with ledger: %w", err)
+	}
+	report.LedgerMismatches = ledgerMismatches
+
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// correct moves the stored balance to the one derived from history. The
+// update is relative, so transfers committed since the mismatch was detected
+// are preserved. If the ledger disagrees with the corrected balance, an
+// adjustment entry brings it in line.
+func (s *reconciliationService) correct(ctx context.Context, mismatch *models.BalanceMismatch) error {
+	diff := mismatch.Difference()
+	if diff == 0 {
+		return nil
+	}
+
+	return s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Users.UpdateCoins(ctx, mismatch.UserID, diff); err != nil {
+			return fmt.Errorf("error updating balance: %w", err)
+		}
+
+		user, err := repos.Users.GetByID(ctx, mismatch.UserID)
+		if err != nil {
+			return fmt.Errorf("error getting user: %w", err)
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
+
+		account, err := userAccount(ctx, repos.Ledger, mismatch.UserID)
+		if err != nil {
+			return err
+		}
+
+		balance, err := repos.Ledger.GetBalance(ctx, account.ID)
+		if err != nil {
+			return fmt.Errorf("error getting ledger balance: %w", err)
+		}
+
+		if balance == user.Coins {
+			return nil
+		}
+		return postAdjustment(ctx, repos.Ledger, mismatch.UserID, user.Coins-balance)
+	})
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"avito-shop/internal/test/backend"
+	"context"
+	"testing"
+)
+
+func TestReconciliationService_Reconcile(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	transRepo := repos.Transactions
+	ledgerRepo := repos.Ledger
+
+	userService := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+	service := NewReconciliationService(transRepo, ledgerRepo, txManager)
+
+	ctx := context.Background()
+
+	for _, name := range []string{"sender", "recipient"} {
+		if err := userService.Register(ctx, name, "testpass"); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sender, err := userRepo.GetByUsername(ctx, "sender")
+	if err != nil || sender == nil {
+		t.Fatal("Failed to get sender")
+	}
+
+	if err := userService.TransferCoins(ctx, sender.ID, "recipient", 150); err != nil {
+		t.Fatalf("Failed to transfer coins: %v", err)
+	}
+
+	report, err := service.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("Reconcile() reported mismatches on a consistent database: %+v", report)
+	}
+
+	// Simulate a debit that was never recorded in the history.
+	if err := repos.Users.UpdateCoins(ctx, sender.ID, -40); err != nil {
+		t.Fatalf("Failed to tamper with balance: %v", err)
+	}
+
+	report, err = service.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.HistoryMismatches) != 1 || report.HistoryMismatches[0].ExpectedBalance != 850 {
+		t.Fatalf("HistoryMismatches = %+v, want one with expected balance 850", report.HistoryMismatches)
+	}
+	if report.Corrected != 0 {
+		t.Errorf("Corrected = %d without fix, want 0", report.Corrected)
+	}
+
+	report, err = service.Reconcile(ctx, true)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Corrected != 1 {
+		t.Errorf("Corrected = %d, want 1", report.Corrected)
+	}
+
+	user, err := userRepo.GetByID(ctx, sender.ID)
+	if err != nil {
+		t.Fatalf("Failed to get sender: %v", err)
+	}
+	if user.Coins != 850 {
+		t.Errorf("Sender coins after fix = %d, want %d", user.Coins, 850)
+	}
+
+	report, err = service.Reconcile(ctx, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.Consistent() {
+		t.Errorf("Reconcile() after fix = %+v, want no mismatches", report)
+	}
+}
@@ -4,54 +4,162 @@ import (
 	"avito-shop/internal/domain/models"
 	"avito-shop/internal/repository"
 	"context"
+	"log/slog"
+	"time"
 )
 
 type UserService interface {
 	Register(ctx context.Context, username, password string) error
-	Login(ctx context.Context, username, password string) (string, error)
 	TransferCoins(ctx context.Context, fromUserID int64, toUsername string, amount int) error
+	// AssignRole changes the role of a user. Tokens issued earlier keep the
+	// old role until they expire.
+	AssignRole(ctx context.Context, username, role string) error
+	// SyncAdmins makes the existing users in usernames admins and demotes
+	// every other admin to a regular user. Accounts are never created; the
+	// usernames that do not exist are returned.
+	SyncAdmins(ctx context.Context, usernames []string) (missing []string, err error)
 }
 
+// AuthService authenticates users. Login and Refresh return a short-lived
+// JWT access token together with an opaque refresh token that is stored
+// server-side and replaced on every refresh. Logout revokes the access token
+// and every refresh token of its session.
+type AuthService interface {
+	// Login checks the credentials of a user connecting from clientIP.
+	// Failures are counted per username and per IP; once either is locked
+	// out Login returns ErrLoginLocked without checking the password.
+	Login(ctx context.Context, username, password, clientIP string) (*models.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, claims *models.AccessClaims) error
+	// ParseAccessToken verifies the signature, expiry and revocation status
+	// of an access token.
+	ParseAccessToken(ctx context.Context, token string) (*models.AccessClaims, error)
+	DeleteExpiredTokens(ctx context.Context) error
+	// DeleteStaleLoginAttempts forgets failed logins that no longer count
+	// towards a lockout.
+	DeleteStaleLoginAttempts(ctx context.Context) error
+}
+
+// MerchandiseService serves the catalogue and purchases. GetAll returns the
+// items on sale; ListAll and the write methods back the admin API and also
+// see deactivated items.
 type MerchandiseService interface {
 	GetAll(ctx context.Context) ([]*models.Merchandise, error)
+	GetByName(ctx context.Context, name string) (*models.Merchandise, error)
 	BuyItem(ctx context.Context, userID int64, itemName string) error
+	ListAll(ctx context.Context) ([]*models.Merchandise, error)
+	Create(ctx context.Context, name string, price int) (*models.Merchandise, error)
+	Update(ctx context.Context, name string, update models.MerchandiseUpdate) (*models.Merchandise, error)
+	Delete(ctx context.Context, name string) error
 }
 
 type InfoService interface {
 	GetUserInfo(ctx context.Context, userID int64) (*models.InfoResponse, error)
 }
 
+// TransactionService pages through a user's transaction history. cursor is
+// the NextCursor of the previous page, or empty for the first one.
+type TransactionService interface {
+	ListTransactions(ctx context.Context, filter models.TransactionFilter, cursor string) (*models.TransactionPage, error)
+}
+
+// IdempotencyService deduplicates retried state-changing requests. Begin
+// either reserves the key for a new request or, with replay set, returns the
+// stored record whose response must be sent back instead of re-executing.
+// Keys expire after a TTL, so a reservation that was never completed or
+// released does not block its key for good.
+type IdempotencyService interface {
+	Begin(ctx context.Context, userID int64, key, requestHash string) (record *models.IdempotencyKey, replay bool, err error)
+	Complete(ctx context.Context, id int64, status int, body []byte) error
+	Release(ctx context.Context, id int64) error
+	// DeleteExpiredKeys removes the keys older than the configured TTL.
+	DeleteExpiredKeys(ctx context.Context) error
+}
+
+// LedgerService reads the double-entry ledger that backs every coin
+// movement. users.coins is a cached balance that must always equal the
+// ledger balance of the user's account.
+type LedgerService interface {
+	GetUserBalance(ctx context.Context, userID int64) (int, error)
+	FindBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error)
+}
+
+// ReconciliationService recomputes balances from the transaction history and
+// the ledger and reports every user whose stored coins disagree. With fix set
+// it brings users.coins in line with the history and records an adjustment
+// in the ledger.
+type ReconciliationService interface {
+	Reconcile(ctx context.Context, fix bool) (*models.ReconciliationReport, error)
+}
+
 type Services struct {
-	Users       UserService
-	Merchandise MerchandiseService
-	Info        InfoService
-	TokenSecret string
+	Users          UserService
+	Auth           AuthService
+	Merchandise    MerchandiseService
+	Info           InfoService
+	Transactions   TransactionService
+	Idempotency    IdempotencyService
+	Ledger         LedgerService
+	Reconciliation ReconciliationService
 }
 
 type ServicesDeps struct {
-	Repos       *repository.Repositories
-	TokenSecret string
+	Repos           *repository.Repositories
+	TxManager       repository.TxManager
+	TokenSecret     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	LoginLockout    LockoutPolicy
+	IdempotencyTTL  time.Duration
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
 }
 
+// NewServices wires the services to deps. Every service is wrapped so that
+// its method calls are traced.
 func NewServices(deps ServicesDeps) *Services {
 	return &Services{
-		Users: NewUserService(
+		Users: tracedUserService{NewUserService(
 			deps.Repos.Users,
 			deps.Repos.Transactions,
-			deps.TokenSecret,
-		),
-		Merchandise: NewMerchandiseService(
+			deps.TxManager,
+			UserServiceConfig{
+				Logger: deps.Logger,
+			},
+		)},
+		Auth: tracedAuthService{NewAuthService(
+			deps.Repos.Users,
+			deps.Repos.Tokens,
+			deps.Repos.LoginAttempts,
+			deps.TxManager,
+			AuthServiceConfig{
+				TokenSecret:     deps.TokenSecret,
+				AccessTokenTTL:  deps.AccessTokenTTL,
+				RefreshTokenTTL: deps.RefreshTokenTTL,
+				Lockout:         deps.LoginLockout,
+				Logger:          deps.Logger,
+			},
+		)},
+		Merchandise: tracedMerchandiseService{NewMerchandiseService(
 			deps.Repos.Users,
 			deps.Repos.Merchandise,
 			deps.Repos.Inventory,
 			deps.Repos.Transactions,
-		),
-		Info: NewInfoService(
+			deps.TxManager,
+		)},
+		Info: tracedInfoService{NewInfoService(
 			deps.Repos.Users,
 			deps.Repos.Merchandise,
 			deps.Repos.Transactions,
 			deps.Repos.Inventory,
-		),
-		TokenSecret: deps.TokenSecret,
+		)},
+		Transactions: tracedTransactionService{NewTransactionService(deps.Repos.Transactions)},
+		Idempotency:  tracedIdempotencyService{NewIdempotencyService(deps.Repos.Idempotency, deps.IdempotencyTTL)},
+		Ledger:       tracedLedgerService{NewLedgerService(deps.Repos.Ledger)},
+		Reconciliation: tracedReconciliationService{NewReconciliationService(
+			deps.Repos.Transactions,
+			deps.Repos.Ledger,
+			deps.TxManager,
+		)},
 	}
 }
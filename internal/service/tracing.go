@@ -0,0 +1,252 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The traced* types wrap each service so that every method call becomes a
+// span. NewServices applies them; the implementations stay free of tracing
+// code.
+
+var tracer = otel.Tracer("avito-shop/internal/service")
+
+// endSpan records err on span and ends it. Errors the client caused are
+// recorded as events only; the span is marked failed for internal errors.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if KindOf(err) == KindInternal {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+type tracedUserService struct{ next UserService }
+
+func (s tracedUserService) Register(ctx context.Context, username, password string) error {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	err := s.next.Register(ctx, username, password)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedUserService) TransferCoins(ctx context.Context, fromUserID int64, toUsername string, amount int) error {
+	ctx, span := tracer.Start(ctx, "UserService.TransferCoins", trace.WithAttributes(
+		attribute.Int64("user.id", fromUserID),
+		attribute.Int("coins.amount", amount),
+	))
+	err := s.next.TransferCoins(ctx, fromUserID, toUsername, amount)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedUserService) AssignRole(ctx context.Context, username, role string) error {
+	ctx, span := tracer.Start(ctx, "UserService.AssignRole")
+	err := s.next.AssignRole(ctx, username, role)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedUserService) SyncAdmins(ctx context.Context, usernames []string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "UserService.SyncAdmins")
+	missing, err := s.next.SyncAdmins(ctx, usernames)
+	endSpan(span, err)
+	return missing, err
+}
+
+type tracedAuthService struct{ next AuthService }
+
+func (s tracedAuthService) Login(ctx context.Context, username, password, clientIP string) (*models.TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	pair, err := s.next.Login(ctx, username, password, clientIP)
+	endSpan(span, err)
+	return pair, err
+}
+
+func (s tracedAuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Refresh")
+	pair, err := s.next.Refresh(ctx, refreshToken)
+	endSpan(span, err)
+	return pair, err
+}
+
+func (s tracedAuthService) Logout(ctx context.Context, claims *models.AccessClaims) error {
+	ctx, span := tracer.Start(ctx, "AuthService.Logout")
+	err := s.next.Logout(ctx, claims)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedAuthService) ParseAccessToken(ctx context.Context, token string) (*models.AccessClaims, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ParseAccessToken")
+	claims, err := s.next.ParseAccessToken(ctx, token)
+	endSpan(span, err)
+	return claims, err
+}
+
+func (s tracedAuthService) DeleteExpiredTokens(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DeleteExpiredTokens")
+	err := s.next.DeleteExpiredTokens(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedAuthService) DeleteStaleLoginAttempts(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DeleteStaleLoginAttempts")
+	err := s.next.DeleteStaleLoginAttempts(ctx)
+	endSpan(span, err)
+	return err
+}
+
+type tracedMerchandiseService struct{ next MerchandiseService }
+
+func (s tracedMerchandiseService) GetAll(ctx context.Context) ([]*models.Merchandise, error) {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.GetAll")
+	items, err := s.next.GetAll(ctx)
+	endSpan(span, err)
+	return items, err
+}
+
+func (s tracedMerchandiseService) GetByName(ctx context.Context, name string) (*models.Merchandise, error) {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.GetByName", trace.WithAttributes(
+		attribute.String("merch.name", name),
+	))
+	item, err := s.next.GetByName(ctx, name)
+	endSpan(span, err)
+	return item, err
+}
+
+func (s tracedMerchandiseService) BuyItem(ctx context.Context, userID int64, itemName string) error {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.BuyItem", trace.WithAttributes(
+		attribute.Int64("user.id", userID),
+		attribute.String("merch.name", itemName),
+	))
+	err := s.next.BuyItem(ctx, userID, itemName)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedMerchandiseService) ListAll(ctx context.Context) ([]*models.Merchandise, error) {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.ListAll")
+	items, err := s.next.ListAll(ctx)
+	endSpan(span, err)
+	return items, err
+}
+
+func (s tracedMerchandiseService) Create(ctx context.Context, name string, price int) (*models.Merchandise, error) {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.Create", trace.WithAttributes(
+		attribute.String("merch.name", name),
+	))
+	item, err := s.next.Create(ctx, name, price)
+	endSpan(span, err)
+	return item, err
+}
+
+func (s tracedMerchandiseService) Update(ctx context.Context, name string, update models.MerchandiseUpdate) (*models.Merchandise, error) {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.Update", trace.WithAttributes(
+		attribute.String("merch.name", name),
+	))
+	item, err := s.next.Update(ctx, name, update)
+	endSpan(span, err)
+	return item, err
+}
+
+func (s tracedMerchandiseService) Delete(ctx context.Context, name string) error {
+	ctx, span := tracer.Start(ctx, "MerchandiseService.Delete", trace.WithAttributes(
+		attribute.String("merch.name", name),
+	))
+	err := s.next.Delete(ctx, name)
+	endSpan(span, err)
+	return err
+}
+
+type tracedInfoService struct{ next InfoService }
+
+func (s tracedInfoService) GetUserInfo(ctx context.Context, userID int64) (*models.InfoResponse, error) {
+	ctx, span := tracer.Start(ctx, "InfoService.GetUserInfo", trace.WithAttributes(
+		attribute.Int64("user.id", userID),
+	))
+	info, err := s.next.GetUserInfo(ctx, userID)
+	endSpan(span, err)
+	return info, err
+}
+
+type tracedTransactionService struct{ next TransactionService }
+
+func (s tracedTransactionService) ListTransactions(ctx context.Context, filter models.TransactionFilter, cursor string) (*models.TransactionPage, error) {
+	ctx, span := tracer.Start(ctx, "TransactionService.ListTransactions", trace.WithAttributes(
+		attribute.Int64("user.id", filter.UserID),
+	))
+	page, err := s.next.ListTransactions(ctx, filter, cursor)
+	endSpan(span, err)
+	return page, err
+}
+
+type tracedIdempotencyService struct{ next IdempotencyService }
+
+func (s tracedIdempotencyService) Begin(ctx context.Context, userID int64, key, requestHash string) (*models.IdempotencyKey, bool, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Begin", trace.WithAttributes(
+		attribute.Int64("user.id", userID),
+	))
+	record, replay, err := s.next.Begin(ctx, userID, key, requestHash)
+	span.SetAttributes(attribute.Bool("idempotency.replay", replay))
+	endSpan(span, err)
+	return record, replay, err
+}
+
+func (s tracedIdempotencyService) Complete(ctx context.Context, id int64, status int, body []byte) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Complete")
+	err := s.next.Complete(ctx, id, status, body)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedIdempotencyService) Release(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Release")
+	err := s.next.Release(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (s tracedIdempotencyService) DeleteExpiredKeys(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.DeleteExpiredKeys")
+	err := s.next.DeleteExpiredKeys(ctx)
+	endSpan(span, err)
+	return err
+}
+
+type tracedLedgerService struct{ next LedgerService }
+
+func (s tracedLedgerService) GetUserBalance(ctx context.Context, userID int64) (int, error) {
+	ctx, span := tracer.Start(ctx, "LedgerService.GetUserBalance", trace.WithAttributes(
+		attribute.Int64("user.id", userID),
+	))
+	balance, err := s.next.GetUserBalance(ctx, userID)
+	endSpan(span, err)
+	return balance, err
+}
+
+func (s tracedLedgerService) FindBalanceMismatches(ctx context.Context) ([]*models.BalanceMismatch, error) {
+	ctx, span := tracer.Start(ctx, "LedgerService.FindBalanceMismatches")
+	mismatches, err := s.next.FindBalanceMismatches(ctx)
+	endSpan(span, err)
+	return mismatches, err
+}
+
+type tracedReconciliationService struct{ next ReconciliationService }
+
+func (s tracedReconciliationService) Reconcile(ctx context.Context, fix bool) (*models.ReconciliationReport, error) {
+	ctx, span := tracer.Start(ctx, "ReconciliationService.Reconcile", trace.WithAttributes(
+		attribute.Bool("reconciliation.fix", fix),
+	))
+	report, err := s.next.Reconcile(ctx, fix)
+	endSpan(span, err)
+	return report, err
+}
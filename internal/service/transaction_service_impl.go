@@ -0,0 +1,126 @@
+package service
+
+import (
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+type transactionService struct {
+	transactions repository.TransactionRepository
+}
+
+func NewTransactionService(transactions repository.TransactionRepository) TransactionService {
+	return &transactionService{
+		transactions: transactions,
+	}
+}
+
+func (s *transactionService) ListTransactions(ctx context.Context, filter models.TransactionFilter, cursor string) (*models.TransactionPage, error) {
+	if filter.UserID == 0 {
+		return nil, validationError("invalid_user_id", "invalid user ID")
+	}
+
+	switch filter.Direction {
+	case "", models.DirectionSent, models.DirectionReceived:
+	default:
+		return nil, validationError("invalid_filter", "invalid direction %q", filter.Direction)
+	}
+
+	switch filter.Type {
+	case "", models.TransactionTypeTransfer, models.TransactionTypePurchase:
+	default:
+		return nil, validationError("invalid_filter", "invalid transaction type %q", filter.Type)
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, validationError("invalid_filter", "from must be before to")
+	}
+
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultTransactionPageSize
+	case filter.Limit < 0 || filter.Limit > maxTransactionPageSize:
+		return nil, validationError("invalid_filter", "limit must be between 1 and %d", maxTransactionPageSize)
+	}
+
+	if cursor != "" {
+		beforeID, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.BeforeID = beforeID
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	pageSize := filter.Limit
+	filter.Limit++
+
+	rows, err := s.transactions.ListUserHistory(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting transactions: %w", err)
+	}
+
+	page := &models.TransactionPage{
+		Transactions: make([]models.TransactionHistoryItem, 0, pageSize),
+	}
+
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		page.NextCursor = encodeTransactionCursor(rows[pageSize-1].ID)
+	}
+
+	for _, t := range rows {
+		item := models.TransactionHistoryItem{
+			ID:        t.ID,
+			Type:      t.TransactionType,
+			Amount:    t.Amount,
+			CreatedAt: t.CreatedAt,
+		}
+
+		switch {
+		case t.ToUserID != nil && *t.ToUserID == filter.UserID:
+			item.Direction = models.DirectionReceived
+			item.Counterparty = usernameOrUnknown(t.FromUsername)
+		case t.TransactionType == models.TransactionTypePurchase:
+			item.Direction = models.DirectionSent
+			item.Counterparty = models.ShopAccountName
+		default:
+			item.Direction = models.DirectionSent
+			item.Counterparty = usernameOrUnknown(t.ToUsername)
+		}
+
+		page.Transactions = append(page.Transactions, item)
+	}
+
+	return page, nil
+}
+
+var errInvalidCursor = &Error{Kind: KindValidation, Code: "invalid_cursor", Message: "invalid cursor"}
+
+// Cursors are opaque to clients; they carry the id of the last row served.
+func encodeTransactionCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeTransactionCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidCursor
+	}
+
+	return id, nil
+}
@@ -2,33 +2,59 @@ package service
 
 import (
 	"avito-shop/internal/domain/models"
+	"avito-shop/internal/metrics"
 	"avito-shop/internal/repository"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
 
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// initialCoins is granted to every new user.
+const initialCoins = 1000
+
+type UserServiceConfig struct {
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+}
+
 type userServiceImpl struct {
 	users        repository.UserRepository
 	transactions repository.TransactionRepository
-	tokenSecret  string
+	txManager    repository.TxManager
+	logger       *slog.Logger
 }
 
-func NewUserService(users repository.UserRepository, transactions repository.TransactionRepository, tokenSecret string) UserService {
+func NewUserService(
+	users repository.UserRepository,
+	transactions repository.TransactionRepository,
+	txManager repository.TxManager,
+	cfg UserServiceConfig,
+) UserService {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &userServiceImpl{
 		users:        users,
 		transactions: transactions,
-		tokenSecret:  tokenSecret,
+		txManager:    txManager,
+		logger:       logger,
 	}
 }
 
 func (s *userServiceImpl) Register(ctx context.Context, username, password string) error {
 	if username == "" || password == "" {
-		return fmt.Errorf("username and password are required")
+		return ErrCredentialsRequired
+	}
+	// The shop is shown as a counterparty under this name, so no account may
+	// take it.
+	if username == models.ShopAccountName {
+		return ErrUserExists
 	}
 
 	existingUser, err := s.users.GetByUsername(ctx, username)
@@ -36,7 +62,7 @@ func (s *userServiceImpl) Register(ctx context.Context, username, password strin
 		return fmt.Errorf("error checking existing user: %w", err)
 	}
 	if existingUser != nil {
-		return fmt.Errorf("user already exists")
+		return ErrUserExists
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -47,99 +73,160 @@ func (s *userServiceImpl) Register(ctx context.Context, username, password strin
 	user := &models.User{
 		Username:     username,
 		PasswordHash: string(hashedPassword),
-		Coins:        1000,
+		Coins:        initialCoins,
+		Role:         models.RoleUser,
 	}
 
-	if err := s.users.Create(ctx, user); err != nil {
-		return fmt.Errorf("error creating user: %w", err)
-	}
+	return s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Users.Create(ctx, user); err != nil {
+			// A concurrent registration took the name after the check above.
+			if errors.Is(err, repository.ErrDuplicate) {
+				return ErrUserExists
+			}
+			return fmt.Errorf("error creating user: %w", err)
+		}
 
-	return nil
+		return openUserAccount(ctx, repos.Ledger, user.ID, user.Coins)
+	})
 }
 
-func (s *userServiceImpl) Login(ctx context.Context, username, password string) (string, error) {
-	log.Printf("Attempting to log in user: %s", username)
-	if username == "" || password == "" {
-		return "", fmt.Errorf("username and password are required")
+func (s *userServiceImpl) AssignRole(ctx context.Context, username, role string) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return validationError("invalid_role", "unknown role %q", role)
 	}
 
 	user, err := s.users.GetByUsername(ctx, username)
 	if err != nil {
-		return "", fmt.Errorf("error getting user: %w", err)
+		return fmt.Errorf("error getting user: %w", err)
 	}
 	if user == nil {
-		log.Printf("User not found: %s", username)
-		return "", fmt.Errorf("user not found")
+		return ErrUserNotFound
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return "", fmt.Errorf("invalid password")
+	if user.Role == role {
+		return nil
 	}
 
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+	if err := s.users.UpdateRole(ctx, user.ID, role); err != nil {
+		return fmt.Errorf("error updating role: %w", err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.tokenSecret))
-	if err != nil {
-		return "", fmt.Errorf("error generating token: %w", err)
-	}
-
-	return tokenString, nil
+	return nil
 }
 
-func (s *userServiceImpl) TransferCoins(ctx context.Context, fromUserID int64, toUsername string, amount int) error {
-	if amount <= 0 {
-		return fmt.Errorf("amount must be positive")
-	}
-
-	if fromUserID == 0 {
-		return fmt.Errorf("invalid sender ID")
-	}
-
-	fromUser, err := s.users.GetByID(ctx, fromUserID)
+func (s *userServiceImpl) SyncAdmins(ctx context.Context, usernames []string) ([]string, error) {
+	listed := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		listed[username] = struct{}{}
+	}
+
+	var missing []string
+	err := s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		missing = nil
+
+		admins, err := repos.Users.ListByRole(ctx, models.RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("error listing admins: %w", err)
+		}
+		for _, admin := range admins {
+			if _, ok := listed[admin.Username]; ok {
+				continue
+			}
+			if err := repos.Users.UpdateRole(ctx, admin.ID, models.RoleUser); err != nil {
+				return fmt.Errorf("error revoking admin role of %s: %w", admin.Username, err)
+			}
+			s.logger.InfoContext(ctx, "Admin role revoked", "username", admin.Username)
+		}
+
+		for _, username := range usernames {
+			user, err := repos.Users.GetByUsername(ctx, username)
+			if err != nil {
+				return fmt.Errorf("error getting user %s: %w", username, err)
+			}
+			if user == nil {
+				missing = append(missing, username)
+				continue
+			}
+			if user.Role == models.RoleAdmin {
+				continue
+			}
+			if err := repos.Users.UpdateRole(ctx, user.ID, models.RoleAdmin); err != nil {
+				return fmt.Errorf("error granting admin role to %s: %w", username, err)
+			}
+			s.logger.InfoContext(ctx, "Admin role granted", "username", username)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("error getting sender: %w", err)
-	}
-	if fromUser == nil {
-		return fmt.Errorf("sender not found")
+		return nil, err
 	}
 
-	if fromUser.Coins < amount {
-		return fmt.Errorf("insufficient funds: have %d, need %d", fromUser.Coins, amount)
-	}
+	return missing, nil
+}
 
-	toUser, err := s.users.GetByUsername(ctx, toUsername)
+func (s *userServiceImpl) TransferCoins(ctx context.Context, fromUserID int64, toUsername string, amount int) error {
+	err := s.transferCoins(ctx, fromUserID, toUsername, amount)
+	attrs := []any{"from_user_id", fromUserID, "to_username", toUsername, "amount", amount}
 	if err != nil {
-		return fmt.Errorf("error getting recipient: %w", err)
-	}
-	if toUser == nil {
-		return fmt.Errorf("recipient not found")
-	}
-
-	transaction := &models.Transaction{
-		FromUserID:      fromUserID,
-		ToUserID:        &toUser.ID,
-		Amount:          amount,
-		TransactionType: models.TransactionTypeTransfer,
-	}
-
-	if err := s.users.UpdateCoins(ctx, fromUserID, -amount); err != nil {
-		return fmt.Errorf("error updating sender balance: %w", err)
-	}
-
-	if err := s.users.UpdateCoins(ctx, toUser.ID, amount); err != nil {
-		_ = s.users.UpdateCoins(ctx, fromUserID, amount)
-		return fmt.Errorf("error updating recipient balance: %w", err)
+		s.logger.WarnContext(ctx, "Coin transfer failed", append(attrs, "error", err)...)
+		return err
 	}
+	s.logger.InfoContext(ctx, "Coins transferred", attrs...)
+	metrics.RecordCoins(metrics.OperationTransfer, amount)
+	return nil
+}
 
-	if err := s.transactions.Create(ctx, transaction); err != nil {
-		_ = s.users.UpdateCoins(ctx, fromUserID, amount)
-		_ = s.users.UpdateCoins(ctx, toUser.ID, -amount)
-		return fmt.Errorf("error recording transaction: %w", err)
+func (s *userServiceImpl) transferCoins(ctx context.Context, fromUserID int64, toUsername string, amount int) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
 	}
 
-	return nil
+	if fromUserID == 0 {
+		return validationError("invalid_user_id", "invalid sender ID")
+	}
+
+	return s.txManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		toUser, err := repos.Users.GetByUsername(ctx, toUsername)
+		if err != nil {
+			return fmt.Errorf("error getting recipient: %w", err)
+		}
+		if toUser == nil {
+			return ErrRecipientNotFound
+		}
+
+		// Opposite transfers between two users update the same rows in
+		// opposite order; locking both up front in ID order prevents them
+		// from deadlocking.
+		if err := repos.Users.LockForUpdate(ctx, fromUserID, toUser.ID); err != nil {
+			return fmt.Errorf("error locking users: %w", err)
+		}
+
+		transaction := &models.Transaction{
+			FromUserID:      fromUserID,
+			ToUserID:        &toUser.ID,
+			Amount:          amount,
+			TransactionType: models.TransactionTypeTransfer,
+		}
+
+		if err := repos.Users.Debit(ctx, fromUserID, amount); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			if errors.Is(err, repository.ErrInsufficientFunds) {
+				return withCause(ErrInsufficientFunds, err)
+			}
+			return fmt.Errorf("error updating sender balance: %w", err)
+		}
+
+		if err := repos.Users.UpdateCoins(ctx, toUser.ID, amount); err != nil {
+			return fmt.Errorf("error updating recipient balance: %w", err)
+		}
+
+		if err := repos.Transactions.Create(ctx, transaction); err != nil {
+			return fmt.Errorf("error recording transaction: %w", err)
+		}
+
+		return postTransfer(ctx, repos.Ledger, transaction)
+	})
 }
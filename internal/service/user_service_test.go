@@ -1,20 +1,17 @@
 package service
 
 import (
-	"avito-shop/internal/repository/postgres"
-	"avito-shop/internal/test"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/test/backend"
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
 func TestUserService_Register(t *testing.T) {
-	db, cleanup := test.SetupTestDB(t)
-	defer cleanup()
-
-	userRepo := postgres.NewUserRepository(db)
-	transRepo := postgres.NewTransactionRepository(db)
-	service := NewUserService(userRepo, transRepo, "test-secret")
-
 	tests := []struct {
 		name     string
 		username string
@@ -39,11 +36,19 @@ func TestUserService_Register(t *testing.T) {
 			password: "",
 			wantErr:  true,
 		},
+		{
+			name:     "Shop account name",
+			username: models.ShopAccountName,
+			password: "testpass",
+			wantErr:  true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			test.ClearTestDB(t, db)
+			repos, txManager := backend.New(t)
+			userRepo := repos.Users
+			service := NewUserService(userRepo, repos.Transactions, txManager, UserServiceConfig{})
 
 			err := service.Register(context.Background(), tt.username, tt.password)
 			if (err != nil) != tt.wantErr {
@@ -68,12 +73,11 @@ func TestUserService_Register(t *testing.T) {
 }
 
 func TestUserService_TransferCoins(t *testing.T) {
-	db, cleanup := test.SetupTestDB(t)
-	defer cleanup()
+	repos, txManager := backend.New(t)
 
-	userRepo := postgres.NewUserRepository(db)
-	transRepo := postgres.NewTransactionRepository(db)
-	service := NewUserService(userRepo, transRepo, "test-secret")
+	userRepo := repos.Users
+	transRepo := repos.Transactions
+	service := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
 
 	ctx := context.Background()
 
@@ -170,3 +174,256 @@ func TestUserService_TransferCoins(t *testing.T) {
 		})
 	}
 }
+
+type failingTransactionRepo struct {
+	repository.TransactionRepository
+}
+
+func (failingTransactionRepo) Create(ctx context.Context, transaction *models.Transaction) error {
+	return errors.New("insert failed")
+}
+
+// failingTxManager delegates to a real transaction but makes recording the
+// transaction fail, so the balance updates that precede it must roll back.
+type failingTxManager struct {
+	repository.TxManager
+}
+
+func (m failingTxManager) WithinTx(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	return m.TxManager.WithinTx(ctx, func(repos *repository.Repositories) error {
+		repos.Transactions = failingTransactionRepo{repos.Transactions}
+		return fn(repos)
+	})
+}
+
+func TestUserService_TransferCoins_RollsBack(t *testing.T) {
+	repos, baseTxManager := backend.New(t)
+
+	userRepo := repos.Users
+	transRepo := repos.Transactions
+	txManager := failingTxManager{baseTxManager}
+	service := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+
+	ctx := context.Background()
+
+	for _, name := range []string{"sender", "recipient"} {
+		if err := service.Register(ctx, name, "testpass"); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sender, err := userRepo.GetByUsername(ctx, "sender")
+	if err != nil || sender == nil {
+		t.Fatal("Failed to get sender")
+	}
+
+	if err := service.TransferCoins(ctx, sender.ID, "recipient", 100); err == nil {
+		t.Fatal("TransferCoins() expected error, got nil")
+	}
+
+	for _, name := range []string{"sender", "recipient"} {
+		user, err := userRepo.GetByUsername(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", name, err)
+		}
+		if user.Coins != 1000 {
+			t.Errorf("%s coins = %d, want %d", name, user.Coins, 1000)
+		}
+	}
+}
+
+func TestUserService_TransferCoins_Concurrent(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	transRepo := repos.Transactions
+	service := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+
+	ctx := context.Background()
+
+	for _, name := range []string{"sender", "recipient"} {
+		if err := service.Register(ctx, name, "testpass"); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sender, err := userRepo.GetByUsername(ctx, "sender")
+	if err != nil || sender == nil {
+		t.Fatal("Failed to get sender")
+	}
+
+	const (
+		workers = 50
+		amount  = 100
+	)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int32
+		rejected  atomic.Int32
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := service.TransferCoins(ctx, sender.ID, "recipient", amount)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, repository.ErrInsufficientFunds):
+				rejected.Add(1)
+			default:
+				t.Errorf("TransferCoins() unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := int(succeeded.Load()), 1000/amount; got != want {
+		t.Errorf("Successful transfers = %d, want %d", got, want)
+	}
+	if got, want := int(rejected.Load()), workers-1000/amount; got != want {
+		t.Errorf("Rejected transfers = %d, want %d", got, want)
+	}
+
+	sender, err = userRepo.GetByID(ctx, sender.ID)
+	if err != nil {
+		t.Fatalf("Failed to get sender: %v", err)
+	}
+	if sender.Coins != 0 {
+		t.Errorf("Sender coins = %d, want 0", sender.Coins)
+	}
+
+	recipient, err := userRepo.GetByUsername(ctx, "recipient")
+	if err != nil {
+		t.Fatalf("Failed to get recipient: %v", err)
+	}
+	if recipient.Coins != 2000 {
+		t.Errorf("Recipient coins = %d, want 2000", recipient.Coins)
+	}
+}
+
+// TestUserService_TransferCoins_Opposite sends coins back and forth between
+// two users at the same time, which deadlocks unless both balances are
+// locked in a consistent order.
+func TestUserService_TransferCoins_Opposite(t *testing.T) {
+	repos, txManager := backend.New(t)
+	service := NewUserService(repos.Users, repos.Transactions, txManager, UserServiceConfig{})
+
+	ctx := context.Background()
+
+	users := map[string]*models.User{}
+	for _, name := range []string{"alice", "bob"} {
+		if err := service.Register(ctx, name, "testpass"); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		user, err := repos.Users.GetByUsername(ctx, name)
+		if err != nil || user == nil {
+			t.Fatalf("Failed to get %s", name)
+		}
+		users[name] = user
+	}
+
+	const rounds = 50
+
+	var wg sync.WaitGroup
+	transfer := func(from *models.User, to string) {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if err := service.TransferCoins(ctx, from.ID, to, 1); err != nil {
+				t.Errorf("TransferCoins() from %s unexpected error: %v", from.Username, err)
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go transfer(users["alice"], "bob")
+	go transfer(users["bob"], "alice")
+	wg.Wait()
+
+	for name, user := range users {
+		got, err := repos.Users.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", name, err)
+		}
+		if got.Coins != initialCoins {
+			t.Errorf("%s has %d coins, want %d", name, got.Coins, initialCoins)
+		}
+	}
+}
+
+func TestUserService_Roles(t *testing.T) {
+	repos, txManager := backend.New(t)
+
+	userRepo := repos.Users
+	transRepo := repos.Transactions
+	service := NewUserService(userRepo, transRepo, txManager, UserServiceConfig{})
+	auth := NewAuthService(userRepo, repos.Tokens, repos.LoginAttempts, txManager, AuthServiceConfig{
+		TokenSecret: "test-secret",
+	})
+
+	ctx := context.Background()
+
+	roleOf := func(t *testing.T, username string) string {
+		t.Helper()
+		pair, err := auth.Login(ctx, username, "testpass", "")
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		claims, err := auth.ParseAccessToken(ctx, pair.AccessToken)
+		if err != nil {
+			t.Fatalf("ParseAccessToken() error = %v", err)
+		}
+		return claims.Role
+	}
+
+	// Registration never grants the admin role, whatever the username.
+	for _, username := range []string{"admin", "testuser", "former"} {
+		if err := service.Register(ctx, username, "testpass"); err != nil {
+			t.Fatalf("Register(%s) error = %v", username, err)
+		}
+		if role := roleOf(t, username); role != models.RoleUser {
+			t.Errorf("Token role of %s = %v, want %v", username, role, models.RoleUser)
+		}
+	}
+
+	if err := service.AssignRole(ctx, "former", models.RoleAdmin); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+	if role := roleOf(t, "former"); role != models.RoleAdmin {
+		t.Errorf("Token role after AssignRole = %q, want %q", role, models.RoleAdmin)
+	}
+
+	if err := service.AssignRole(ctx, "testuser", "superuser"); err == nil {
+		t.Error("AssignRole() with unknown role expected error, got nil")
+	}
+
+	missing, err := service.SyncAdmins(ctx, []string{"admin", "ghost"})
+	if err != nil {
+		t.Fatalf("SyncAdmins() error = %v", err)
+	}
+	if len(missing) != 1 || missing[0] != "ghost" {
+		t.Errorf("SyncAdmins() missing = %v, want [ghost]", missing)
+	}
+
+	wantRoles := map[string]string{
+		"admin":    models.RoleAdmin,
+		"testuser": models.RoleUser,
+		"former":   models.RoleUser,
+	}
+	for username, want := range wantRoles {
+		if role := roleOf(t, username); role != want {
+			t.Errorf("Token role of %s after SyncAdmins = %q, want %q", username, role, want)
+		}
+	}
+
+	if ghost, _ := userRepo.GetByUsername(ctx, "ghost"); ghost != nil {
+		t.Error("SyncAdmins() created an account for a missing username")
+	}
+	if err := service.Register(ctx, "ghost", "testpass"); err != nil {
+		t.Fatalf("Register(ghost) error = %v", err)
+	}
+	if role := roleOf(t, "ghost"); role != models.RoleUser {
+		t.Errorf("Token role of ghost registered after SyncAdmins = %q, want %q", role, models.RoleUser)
+	}
+}
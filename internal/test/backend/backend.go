@@ -0,0 +1,26 @@
+// Package backend gives backend-agnostic tests a fresh store. It is separate
+// from package test because the postgres package's own tests import that
+// package.
+package backend
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/repository/memory"
+	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/test"
+	"testing"
+)
+
+// New returns repositories over an empty store: the in-memory backend by
+// default, or the PostgreSQL test database when opted in with
+// test.BackendEnv. The store is closed when t finishes.
+func New(t testing.TB) (*repository.Repositories, repository.TxManager) {
+	if test.UsePostgres() {
+		db, cleanup := test.SetupTestDB(t)
+		t.Cleanup(cleanup)
+		return postgres.NewRepositories(db), postgres.NewTxManager(db)
+	}
+
+	db := memory.NewDB()
+	return memory.NewRepositories(db), memory.NewTxManager(db)
+}
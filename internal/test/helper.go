@@ -2,6 +2,9 @@ package test
 
 import (
 	"avito-shop/internal/config"
+	"avito-shop/internal/migrate"
+	"avito-shop/migrations"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -11,7 +14,23 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func SetupTestDB(t *testing.T) (*sql.DB, func()) {
+// BackendEnv selects the storage tests run against. Tests default to the
+// in-memory backend; TEST_BACKEND=postgres opts in to the PostgreSQL test
+// database from docker-compose.
+const BackendEnv = "TEST_BACKEND"
+
+// UsePostgres reports whether the PostgreSQL test database is opted in.
+func UsePostgres() bool {
+	return os.Getenv(BackendEnv) == "postgres"
+}
+
+// SetupTestDB returns a freshly migrated PostgreSQL test database. The test
+// is skipped unless PostgreSQL is opted in with BackendEnv.
+func SetupTestDB(t testing.TB) (*sql.DB, func()) {
+	if !UsePostgres() {
+		t.Skipf("PostgreSQL tests are disabled; set %s=postgres to run them", BackendEnv)
+	}
+
 	dbConfig := config.DatabaseConfig{
 		Host:     "localhost",
 		Port:     "5433",
@@ -31,24 +50,19 @@ func SetupTestDB(t *testing.T) (*sql.DB, func()) {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	_, err = db.Exec(`
-		DROP TABLE IF EXISTS coin_transactions;
-		DROP TABLE IF EXISTS user_inventory;
-		DROP TABLE IF EXISTS merchandise;
-		DROP TABLE IF EXISTS users;
-	`)
+	// Recreating the schema also drops the migration table, so every run
+	// starts from an empty database and applies all migrations.
+	_, err = db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`)
 	if err != nil {
-		t.Fatalf("Failed to drop existing tables: %v", err)
+		t.Fatalf("Failed to reset test database: %v", err)
 	}
 
-	schema, err := os.ReadFile("../../migrations/001_initial_schema.sql")
+	migrator, err := migrate.New(db, migrations.FS)
 	if err != nil {
-		t.Fatalf("Failed to read schema file: %v", err)
+		t.Fatalf("Failed to load migrations: %v", err)
 	}
-
-	_, err = db.Exec(string(schema))
-	if err != nil {
-		t.Fatalf("Failed to execute schema: %v", err)
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
 	}
 
 	return db, func() {
@@ -58,12 +72,20 @@ func SetupTestDB(t *testing.T) (*sql.DB, func()) {
 	}
 }
 
-func ClearTestDB(t *testing.T, db *sql.DB) {
-	tables := []string{"coin_transactions", "user_inventory", "merchandise", "users"}
+func ClearTestDB(t testing.TB, db *sql.DB) {
+	tables := []string{"ledger_postings", "ledger_entries", "idempotency_keys", "coin_transactions", "user_inventory", "merchandise", "refresh_tokens", "revoked_access_tokens", "login_attempts"}
 	for _, table := range tables {
 		_, err := db.Exec(fmt.Sprintf("DELETE FROM %s", table))
 		if err != nil {
 			t.Fatalf("Failed to clear table %s: %v", table, err)
 		}
 	}
+
+	// System ledger accounts are seeded by the migrations and must survive.
+	if _, err := db.Exec("DELETE FROM ledger_accounts WHERE user_id IS NOT NULL"); err != nil {
+		t.Fatalf("Failed to clear table ledger_accounts: %v", err)
+	}
+	if _, err := db.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Failed to clear table users: %v", err)
+	}
 }
@@ -0,0 +1,4 @@
+DROP TABLE coin_transactions;
+DROP TABLE user_inventory;
+DROP TABLE merchandise;
+DROP TABLE users;
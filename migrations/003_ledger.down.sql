@@ -0,0 +1,4 @@
+DROP TABLE ledger_postings;
+DROP TABLE ledger_entries;
+DROP TABLE ledger_accounts;
+DROP FUNCTION check_ledger_entry_balanced;
@@ -0,0 +1,75 @@
+CREATE TABLE ledger_accounts (
+    id SERIAL PRIMARY KEY,
+    account_type VARCHAR(50) NOT NULL,
+    user_id INTEGER UNIQUE REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((account_type = 'USER') = (user_id IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX ledger_accounts_system_type_idx
+    ON ledger_accounts (account_type)
+    WHERE user_id IS NULL;
+
+CREATE TABLE ledger_entries (
+    id SERIAL PRIMARY KEY,
+    entry_type VARCHAR(50) NOT NULL,
+    transaction_id INTEGER REFERENCES coin_transactions(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE ledger_postings (
+    id SERIAL PRIMARY KEY,
+    entry_id INTEGER NOT NULL REFERENCES ledger_entries(id),
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id),
+    amount INTEGER NOT NULL CHECK (amount <> 0)
+);
+
+CREATE INDEX ledger_postings_account_id_idx ON ledger_postings (account_id);
+CREATE INDEX ledger_postings_entry_id_idx ON ledger_postings (entry_id);
+
+-- Every journal entry must net to zero. The check is deferred to commit so
+-- that the postings of one entry can be inserted one by one.
+CREATE FUNCTION check_ledger_entry_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM ledger_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT OR UPDATE ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_entry_balanced();
+
+INSERT INTO ledger_accounts (account_type) VALUES ('SHOP'), ('ISSUANCE');
+
+-- Open an account for every existing user and carry the current balance over
+-- from the issuance account.
+INSERT INTO ledger_accounts (account_type, user_id)
+SELECT 'USER', id FROM users;
+
+DO $$
+DECLARE
+    account RECORD;
+    issuance_id INTEGER;
+    new_entry_id INTEGER;
+BEGIN
+    SELECT id INTO issuance_id FROM ledger_accounts WHERE account_type = 'ISSUANCE';
+
+    FOR account IN
+        SELECT a.id, u.coins
+        FROM ledger_accounts a
+        JOIN users u ON u.id = a.user_id
+        WHERE u.coins <> 0
+    LOOP
+        INSERT INTO ledger_entries (entry_type) VALUES ('OPENING_BALANCE')
+        RETURNING id INTO new_entry_id;
+
+        INSERT INTO ledger_postings (entry_id, account_id, amount)
+        VALUES (new_entry_id, account.id, account.coins),
+               (new_entry_id, issuance_id, -account.coins);
+    END LOOP;
+END;
+$$;
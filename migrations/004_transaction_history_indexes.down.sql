@@ -0,0 +1,2 @@
+DROP INDEX coin_transactions_to_user_id_idx;
+DROP INDEX coin_transactions_from_user_id_idx;
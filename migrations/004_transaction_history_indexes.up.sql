@@ -0,0 +1,2 @@
+CREATE INDEX coin_transactions_from_user_id_idx ON coin_transactions (from_user_id, id DESC);
+CREATE INDEX coin_transactions_to_user_id_idx ON coin_transactions (to_user_id, id DESC);
@@ -0,0 +1 @@
+ALTER TABLE merchandise DROP COLUMN active;
@@ -0,0 +1 @@
+ALTER TABLE merchandise ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
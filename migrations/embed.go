@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations into the binary.
+// Every version has an NNN_name.up.sql file and a matching .down.sql file.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS